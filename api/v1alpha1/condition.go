@@ -0,0 +1,19 @@
+package v1alpha1
+
+// Condition types, that are reported within the status of the kubepost resources.
+const (
+	// ConditionReady indicates whether the resource has been reconciled successfully.
+	ConditionReady = "Ready"
+	// ConditionReachable indicates whether the PostgreSQL server of a connection is reachable.
+	ConditionReachable = "Reachable"
+	// ConditionAuthenticated indicates whether the PostgreSQL server accepted the credentials of a connection.
+	ConditionAuthenticated = "Authenticated"
+)
+
+// Phases, that are reported for each connection within the status of the kubepost resources.
+const (
+	// PhaseReady indicates, that the resource has been reconciled successfully on the connection.
+	PhaseReady = "Ready"
+	// PhaseFailed indicates, that the reconciliation of the resource failed on the connection.
+	PhaseFailed = "Failed"
+)
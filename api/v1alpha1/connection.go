@@ -25,12 +25,33 @@ type ConnectionSpec struct {
 
 // ConnectionStatus defines the observed state of Connection
 type ConnectionStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions of the connection. kubepost reports whether the PostgreSQL server is reachable, whether the
+	// configured credentials are accepted and whether the connection is ready to be used.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Version of the PostgreSQL server, as reported by the last successful probe.
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Time of the last probe against the PostgreSQL server.
+	LastProbeTime *metav1.Time `json:"lastProbeTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Error that occurred during the last probe. Empty if the last probe succeeded.
+	Error string `json:"error,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.serverVersion`
+// +kubebuilder:printcolumn:name="Last Probe",type=date,JSONPath=`.status.lastProbeTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Connection is the Schema for the connections API
 type Connection struct {
@@ -6,6 +6,12 @@ import (
 
 // DatabaseSpec defines the desired state of Database
 type DatabaseSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength:=63
+	// Name of the database within PostgreSQL. Defaults to the name of the resource. Changing the name renames the
+	// database.
+	Name string `json:"name,omitempty"`
+
 	// Define which connections shall be used by kubepost for this database.
 	ConnectionSelector metav1.LabelSelector `json:"connectionSelector"`
 	// Narrow down the namespaces for the previously matched connections.
@@ -20,9 +26,65 @@ type DatabaseSpec struct {
 	// Define whether the PostgreSQL database deletion is skipped when the CR is deleted.
 	Protected bool `json:"protected"`
 
+	// +kubebuilder:validation:Optional
+	// Character set encoding of the database, e.g. "UTF8". Can only be set when the database is created.
+	Encoding string `json:"encoding,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Collation order (LC_COLLATE) of the database. Can only be set when the database is created.
+	LCCollate string `json:"lcCollate,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Character classification (LC_CTYPE) of the database. Can only be set when the database is created.
+	LCCtype string `json:"lcCtype,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=libc;icu
+	// Locale provider of the database. Requires PostgreSQL 15 or later. Can only be set when the database is created.
+	LocaleProvider string `json:"localeProvider,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ICU locale of the database, if the locale provider "icu" is used. Can only be set when the database is created.
+	ICULocale string `json:"icuLocale,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Template, the database is created from. Can only be set when the database is created.
+	Template string `json:"template,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Default tablespace of the database. If omitted, the tablespace won't be changed after the database has been
+	// created. Moving a database requires, that no other sessions are connected to it.
+	Tablespace string `json:"tablespace,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=-1
+	// +kubebuilder:default:=-1
+	// Define how many concurrent connections can be made to the database. -1 means no limit.
+	ConnectionLimit int32 `json:"connectionLimit"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	// Define whether connections to the database are allowed. Extensions can't be managed, if connections are
+	// not allowed.
+	AllowConnections bool `json:"allowConnections"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the database can be cloned by any role with CREATEDB privileges.
+	IsTemplate bool `json:"isTemplate"`
+
+	// +kubebuilder:validation:Optional
+	// Runtime settings of the database, e.g. "statement_timeout: 30s". The settings apply to all roles, that
+	// connect to the database. Settings, that are removed, are reset to the server default.
+	Settings map[string]string `json:"settings,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// List of extensions for this database.
 	Extensions []Extension `json:"extensions"`
+
+	// +kubebuilder:validation:Optional
+	// List of schemas for this database.
+	Schemas []Schema `json:"schemas,omitempty"`
 }
 
 type Extension struct {
@@ -34,14 +96,104 @@ type Extension struct {
 	Version string `json:"version,omitempty"`
 }
 
+type Schema struct {
+	// Name of the schema, that shall be managed within the database.
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	// Owner of the schema. If omitted, the owner won't be changed.
+	Owner string `json:"owner,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	// Define whether the deletion of the schema is skipped, when it is removed from the list of schemas.
+	Protected bool `json:"protected"`
+}
+
 // DatabaseStatus defines the observed state of Database
 type DatabaseStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +kubebuilder:validation:Optional
+	// Name of the database within PostgreSQL, as of the last successful reconciliation. It is used to detect renames.
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Generation of the database, that has been reconciled most recently.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions of the database. The database is ready, if it has been reconciled successfully on all matching
+	// connections.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Number of connections, the database has been reconciled successfully on, e.g. "4/5".
+	ReadyConnections string `json:"readyConnections,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Results of the last reconciliation for each matching connection.
+	Connections []DatabaseConnectionStatus `json:"connections,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Unprotected schemas, that are managed by kubepost. These schemas are dropped, once they are removed from
+	// the list of schemas.
+	ManagedSchemas []string `json:"managedSchemas,omitempty"`
+}
+
+type DatabaseConnectionStatus struct {
+	// Namespace of the connection.
+	Namespace string `json:"namespace"`
+
+	// Name of the connection.
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Enum=Ready;Failed
+	// Phase of the database on the connection.
+	Phase string `json:"phase"`
+
+	// Whether the database exists on the connection.
+	Exists bool `json:"exists"`
+
+	// +kubebuilder:validation:Optional
+	// Current owner of the database.
+	Owner string `json:"owner,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Creation parameters, that differ from the desired ones, but can't be changed after the database has been
+	// created.
+	Mismatches []string `json:"mismatches,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Extensions, that are installed within the database.
+	Extensions []ExtensionStatus `json:"extensions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Error that occurred during the last reconciliation. Empty if the last reconciliation succeeded.
+	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// SQLSTATE code of the error, if it has been reported by the PostgreSQL server.
+	SQLState string `json:"sqlState,omitempty"`
+
+	// Time of the last reconciliation.
+	LastReconcileTime metav1.Time `json:"lastReconcileTime"`
+}
+
+type ExtensionStatus struct {
+	// Name of the installed extension.
+	Name string `json:"name"`
+
+	// Installed version of the extension.
+	Version string `json:"version"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Connections",type=string,JSONPath=`.status.readyConnections`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Database is the Schema for the databases API
 type Database struct {
@@ -61,6 +213,14 @@ type DatabaseList struct {
 	Items           []Database `json:"items"`
 }
 
+// PostgresName returns the name of the database within PostgreSQL.
+func (in *Database) PostgresName() string {
+	if in.Spec.Name != "" {
+		return in.Spec.Name
+	}
+	return in.ObjectMeta.Name
+}
+
 func init() {
 	SchemeBuilder.Register(&Database{}, &DatabaseList{})
 }
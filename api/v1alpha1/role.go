@@ -7,6 +7,12 @@ import (
 
 // RoleSpec defines the desired state of Role
 type RoleSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxLength:=63
+	// Name of the role within PostgreSQL. Defaults to the name of the resource. Changing the name renames the
+	// role.
+	Name string `json:"name,omitempty"`
+
 	// Define which connections shall be used by kubepost for this role.
 	ConnectionSelector metav1.LabelSelector `json:"connectionSelector"`
 	// Narrow down the namespaces for the previously matched connections.
@@ -18,25 +24,224 @@ type RoleSpec struct {
 	Protected bool `json:"protected"`
 
 	// +kubebuilder:validation:Optional
-	// Options that shall be applied to this role. Important: Options that are simply removed from the kubepost role
-	// will not be removed from the PostgreSQL role.
-	// E.g.: Granting "SUPERUSER" and then removing the option won't cause kubepost to remove this option from
-	// the role. You have to set the option "NOSUPERUSER".
+	// +kubebuilder:default:=false
+	// Define whether the role is allowed to log in.
+	Login bool `json:"login"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the role is a superuser.
+	Superuser bool `json:"superuser"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the role is allowed to create databases.
+	CreateDB bool `json:"createDB"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the role is allowed to create, alter and drop other roles.
+	CreateRole bool `json:"createRole"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=true
+	// Define whether the role inherits the privileges of the roles it is a member of.
+	Inherit bool `json:"inherit"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the role is a replication role.
+	Replication bool `json:"replication"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=false
+	// Define whether the role bypasses every row-level security policy.
+	BypassRLS bool `json:"bypassRLS"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=-1
+	// +kubebuilder:default:=-1
+	// Define how many concurrent connections the role can make. -1 means no limit.
+	ConnectionLimit int32 `json:"connectionLimit"`
+
+	// +kubebuilder:validation:Optional
+	// Define the date and time after which the password of the role is no longer valid. If omitted, the password
+	// will be valid for all time.
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Deprecated: Use the typed role attributes instead. Options that shall be applied to this role. Well-known
+	// options like "SUPERUSER" or "NOLOGIN" take precedence over the typed role attributes, all other options
+	// are applied as they are and won't be reverted if they are removed.
 	Options []string `json:"options"`
 
 	// +kubebuilder:validation:Optional
-	// Kubernetes secret reference, that is used to set a password for the role.
+	// Kubernetes secret reference, that is used to set a password for the role. The secret may either contain the
+	// plaintext password or an already computed SCRAM-SHA-256 or md5 verifier. Plaintext passwords are hashed by
+	// kubepost and never sent to PostgreSQL.
 	Password *v1.SecretKeySelector `json:"password"`
 
+	// +kubebuilder:validation:Optional
+	// Let kubepost generate a password for the role. The password is stored within a secret in the namespace of the
+	// role, that is owned by the role. Can't be combined with password.
+	PasswordGeneration *PasswordGeneration `json:"passwordGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Let kubepost rotate the generated password of the role periodically. Requires passwordGeneration.
+	Rotation *PasswordRotation `json:"rotation,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Let kubepost write a secret for each matching connection, that contains everything an application needs to
+	// connect to the PostgreSQL server as this role. The secrets are stored in the namespace of the role and are
+	// owned by the role.
+	ConnectionSecret *ConnectionSecret `json:"connectionSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Runtime settings of the role, e.g. "statement_timeout: 30s". The settings apply to all sessions of the role.
+	// Settings, that are removed, are reset to the server default.
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Runtime settings of the role, that only apply within a specific database. They take precedence over the
+	// settings of the role and the settings of the database.
+	DatabaseSettings []RoleDatabaseSettings `json:"databaseSettings,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// Grants that shall be applied to this role.
 	Grants []Grant `json:"grants"`
 
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Transaction;Statement
+	// +kubebuilder:default:=Transaction
+	// Define how grants are applied within a database. "Transaction" applies all grant statements of a database
+	// within a single transaction, that is rolled back on the first failure. "Statement" applies each statement on
+	// its own and continues with the remaining statements on failures.
+	GrantApplyMode string `json:"grantApplyMode"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Authoritative;Additive
+	// +kubebuilder:default:=Authoritative
+	// Define how grants and default privileges are managed. "Authoritative" revokes all privileges of the role, that
+	// are not part of the spec. "Additive" only revokes privileges, that have been granted by kubepost and have been
+	// removed from the spec since, and keeps all other privileges, e.g. privileges granted by migrations.
+	GrantManagement string `json:"grantManagement"`
+
+	// +kubebuilder:validation:Optional
+	// Default privileges, that shall be applied to this role. In contrast to grants, they apply to objects, that
+	// are created in the future.
+	DefaultPrivileges []DefaultPrivilege `json:"defaultPrivileges,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// Groups that shall be applied to this role.
 	Groups []GroupGrantObject `json:"groups"`
 }
 
+type RoleDatabaseSettings struct {
+	// Name of the database, the settings apply to.
+	Database string `json:"database"`
+
+	// Runtime settings of the role within the database.
+	Settings map[string]string `json:"settings"`
+}
+
+type PasswordGeneration struct {
+	// +kubebuilder:validation:Optional
+	// Name of the secret, that kubepost stores the generated password in. Defaults to the name of the role,
+	// suffixed with "-password".
+	SecretName string `json:"secretName,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=password
+	// Key within the secret, that kubepost stores the generated password in.
+	Key string `json:"key"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum:=12
+	// +kubebuilder:validation:Maximum:=128
+	// +kubebuilder:default:=32
+	// Length of the generated password.
+	Length int `json:"length"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Alphanumeric;Printable;Custom
+	// +kubebuilder:default:=Alphanumeric
+	// Characters the generated password consists of. "Printable" adds symbols to the alphanumeric characters,
+	// but omits quotes, backslashes and whitespaces. Those passwords still have to be quoted within shells and
+	// percent-encoded within URIs. "Custom" uses the characters of customCharset.
+	Charset string `json:"charset"`
+
+	// +kubebuilder:validation:Optional
+	// Characters the generated password consists of, if the charset "Custom" is used.
+	CustomCharset string `json:"customCharset,omitempty"`
+}
+
+type PasswordRotation struct {
+	// Interval in which the password is rotated, e.g. "720h".
+	Interval metav1.Duration `json:"interval"`
+
+	// +kubebuilder:validation:Optional
+	// Margin after the next scheduled rotation, after which the current password expires, if it has not been
+	// rotated in time. If set, kubepost sets VALID UNTIL of the role accordingly, which takes precedence over
+	// validUntil. A role has a single password, the previous password stops working as soon as it is rotated.
+	ExpiryMargin *metav1.Duration `json:"expiryMargin,omitempty"`
+}
+
+type ConnectionSecret struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:="{{ .Role }}-{{ .ConnectionNamespace }}-{{ .Connection }}"
+	// Go template for the name of the secrets. The fields .Role, .Connection and .ConnectionNamespace are available
+	// to the template. The rendered names have to be unique across all matching connections.
+	NameTemplate string `json:"nameTemplate"`
+
+	// +kubebuilder:validation:Optional
+	// Database, that is used within the secrets. Defaults to the database of the connection.
+	Database string `json:"database,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:={}
+	// Names of the keys within the secrets.
+	Keys ConnectionSecretKeys `json:"keys"`
+
+	// +kubebuilder:validation:Optional
+	// Additional keys, whose values are rendered from Go templates. The fields .Host, .Port, .Database, .User,
+	// .Password, .SSLMode, .URI and .JDBCURL are available to the templates.
+	Templates map[string]string `json:"templates,omitempty"`
+}
+
+type ConnectionSecretKeys struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=host
+	Host string `json:"host"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=port
+	Port string `json:"port"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=dbname
+	Database string `json:"database"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=user
+	User string `json:"user"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=password
+	Password string `json:"password"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=sslmode
+	SSLMode string `json:"sslMode"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=uri
+	URI string `json:"uri"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=jdbc-uri
+	JDBCURL string `json:"jdbcURL"`
+}
+
 type Grant struct {
 	// Define which database shall the grant be applied to.
 	Database string `json:"database"`
@@ -44,6 +249,32 @@ type Grant struct {
 	Objects []GrantObject `json:"objects"`
 }
 
+type DefaultPrivilege struct {
+	// Define which database shall the default privileges be applied to.
+	Database string `json:"database"`
+
+	// +kubebuilder:validation:Optional
+	// Role, that creates the objects the default privileges apply to. Defaults to the role kubepost connects with.
+	Role string `json:"role,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Schema, the default privileges are restricted to. If omitted, the default privileges apply to all schemas.
+	// Must be omitted for the object type SCHEMAS.
+	Schema string `json:"schema,omitempty"`
+
+	// +kubebuilder:validation:Enum=TABLES;SEQUENCES;FUNCTIONS;TYPES;SCHEMAS
+	// Type of the objects, that the default privileges apply to.
+	ObjectType string `json:"objectType"`
+
+	// Define the privileges for the default privileges.
+	Privileges []Privilege `json:"privileges"`
+
+	// +kubebuilder:validation:Optional
+	// Define whether the `WITH GRANT OPTION` shall be granted. More information can be found within
+	// the official [PostgreSQL](https://www.postgresql.org/docs/current/sql-alterdefaultprivileges.html) documentation.
+	WithGrantOption bool `json:"withGrantOption"`
+}
+
 type GroupGrantObject struct {
 	// +kubebuilder:validation:Required
 	// Define the name of the group.
@@ -56,13 +287,16 @@ type GroupGrantObject struct {
 
 type GrantObject struct {
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=VIEW;COLUMN;TABLE;SCHEMA;FUNCTION;SEQUENCE
-	// Define the type that the grant shall be applied to.
+	// +kubebuilder:validation:Enum=VIEW;MATERIALIZED_VIEW;FOREIGN_TABLE;COLUMN;TABLE;SCHEMA;FUNCTION;PROCEDURE;ROUTINE;SEQUENCE;DATABASE;TYPE;DOMAIN;LANGUAGE;FOREIGN_DATA_WRAPPER;FOREIGN_SERVER;TABLESPACE;LARGE_OBJECT;PARAMETER
+	// Define the type that the grant shall be applied to. Grants on tablespaces and parameters are shared by all
+	// databases of a connection and are therefore applied within the database of the connection, regardless of the
+	// database of the grant. Grants on parameters require PostgreSQL 15 or later.
 	Type string `json:"type"`
 
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default:=public
-	// Define the schema that the grant shall be applied to.
+	// Define the schema that the grant shall be applied to. It is ignored for objects, that don't belong to a
+	// schema.
 	Schema string `json:"schema"`
 
 	// +kubebuilder:validation:Optional
@@ -70,10 +304,32 @@ type GrantObject struct {
 	// TODO
 	Table string `json:"table"`
 
-	// +kubebuilder:validation:Required
-	// Name of the PostgreSQL object (VIEW;COLUMN;TABLE;SCHEMA;FUNCTION;SEQUENCE) that the grant shall be applied to.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Exact;Glob;Regex
+	// +kubebuilder:default:=Regex
+	// Define how the schema, table and identifier are matched. "Exact" matches the names literally, "Glob" supports
+	// the wildcards "*" and "?" and "Regex" matches POSIX regular expressions.
+	MatchType string `json:"matchType"`
+
+	// +kubebuilder:validation:Optional
+	// Patterns of identifiers, that shall be excluded from the grant. They are matched according to matchType.
+	Exclude []string `json:"exclude,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Name of the PostgreSQL object that the grant shall be applied to. Large objects are identified by their OID.
+	// It is ignored for the type DATABASE, which always applies to the database of the grant.
 	Identifier string `json:"identifier"`
 
+	// +kubebuilder:validation:Optional
+	// Argument types of the function or procedure, e.g. "integer, text". If omitted, the grant applies to all
+	// overloads, that match the identifier. The type ROUTINE matches functions as well as procedures.
+	Signature string `json:"signature,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Define whether the grant shall also be applied to the partitions of matching partitioned tables, regardless
+	// of their names.
+	IncludePartitions bool `json:"includePartitions,omitempty"`
+
 	// +kubebuilder:validation:Optional
 	// Define the privileges for the grant.
 	Privileges []Privilege `json:"privileges"`
@@ -83,18 +339,119 @@ type GrantObject struct {
 	WithGrantOption bool `json:"withGrantOption"`
 }
 
-// +kubebuilder:validation:Enum=ALL;SELECT;INSERT;UPDATE;DELETE;TRUNCATE;REFERENCES;TRIGGER;USAGE;CREATE;CONNECT;TEMPORARY;TEMP;EXECUTE
+// +kubebuilder:validation:Enum=ALL;SELECT;INSERT;UPDATE;DELETE;TRUNCATE;REFERENCES;TRIGGER;USAGE;CREATE;CONNECT;TEMPORARY;TEMP;EXECUTE;SET;ALTER_SYSTEM
 
 type Privilege string
 
 // RoleStatus defines the observed state of Role
 type RoleStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +kubebuilder:validation:Optional
+	// Name of the role within PostgreSQL, as of the last successful reconciliation. It is used to detect renames.
+	Name string `json:"name,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Default privileges, that have been applied on all connections by the last successful reconciliation.
+	AppliedDefaultPrivileges []DefaultPrivilege `json:"appliedDefaultPrivileges,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Generation of the role, that has been reconciled most recently.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	// Conditions of the role. The role is ready, if it has been reconciled successfully on all matching connections.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Number of connections, the role has been reconciled successfully on, e.g. "4/5".
+	ReadyConnections string `json:"readyConnections,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Results of the last reconciliation for each matching connection.
+	Connections []RoleConnectionStatus `json:"connections,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Time of the last password change, that has been performed by kubepost.
+	PasswordLastRotated *metav1.Time `json:"passwordLastRotated,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Time of the next scheduled password rotation.
+	NextPasswordRotation *metav1.Time `json:"nextPasswordRotation,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Most recent password rotations, that have been performed by kubepost. The oldest rotation comes first.
+	RotationHistory []PasswordRotationRecord `json:"rotationHistory,omitempty"`
+}
+
+type RoleConnectionStatus struct {
+	// Namespace of the connection.
+	Namespace string `json:"namespace"`
+
+	// Name of the connection.
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Enum=Ready;Failed
+	// Phase of the role on the connection.
+	Phase string `json:"phase"`
+
+	// +kubebuilder:validation:Optional
+	// Error that occurred during the last reconciliation. Empty if the last reconciliation succeeded.
+	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// SQLSTATE code of the error, if it has been reported by the PostgreSQL server.
+	SQLState string `json:"sqlState,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Grant statements, that failed during the last reconciliation.
+	FailedStatements []FailedStatement `json:"failedStatements,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Version of the password, that has been applied on the connection. It consists of the name, key and resource
+	// version of the password secret and is used to detect password changes, if kubepost is not allowed to read
+	// the password verifier of the role.
+	PasswordVersion string `json:"passwordVersion,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Privileges, that have been granted by kubepost on the connection, by database and object. They are only
+	// tracked if the grants are managed additively, in which case only these privileges are revoked.
+	AppliedGrants []Grant `json:"appliedGrants,omitempty"`
+
+	// Time of the last reconciliation.
+	LastReconcileTime metav1.Time `json:"lastReconcileTime"`
+}
+
+type FailedStatement struct {
+	// Database, the statement has been applied within.
+	Database string `json:"database"`
+
+	// Statement, that failed.
+	Statement string `json:"statement"`
+
+	// +kubebuilder:validation:Optional
+	// SQLSTATE code of the error, if it has been reported by the PostgreSQL server.
+	SQLState string `json:"sqlState,omitempty"`
+
+	// Error, that occurred.
+	Message string `json:"message"`
+}
+
+type PasswordRotationRecord struct {
+	// Time of the rotation.
+	Time metav1.Time `json:"time"`
+
+	// +kubebuilder:validation:Optional
+	// Date and time after which the rotated password is no longer valid.
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Connections",type=string,JSONPath=`.status.readyConnections`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Role is the Schema for the roles API
 type Role struct {
@@ -114,6 +471,14 @@ type RoleList struct {
 	Items           []Role `json:"items"`
 }
 
+// PostgresName returns the name of the role within PostgreSQL.
+func (in *Role) PostgresName() string {
+	if in.Spec.Name != "" {
+		return in.Spec.Name
+	}
+	return in.ObjectMeta.Name
+}
+
 func init() {
 	SchemeBuilder.Register(&Role{}, &RoleList{})
 }
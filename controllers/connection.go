@@ -2,46 +2,77 @@ package controllers
 
 import (
 	"context"
+	"time"
+
 	postgresv1alpha1 "github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/connection"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
+// DefaultProbeInterval is the interval in which connections are probed, if no other interval is configured.
+const DefaultProbeInterval = time.Minute
+
 // ConnectionReconciler reconciles a Connection object
 type ConnectionReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Pools  *connection.Manager
+	// ProbeInterval defines how often the PostgreSQL server of a connection is probed.
+	ProbeInterval time.Duration
 }
 
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
-// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 
 // +kubebuilder:rbac:groups=postgres.kubepost.io,resources=connections,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=postgres.kubepost.io,resources=connections/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=postgres.kubepost.io,resources=connections/finalizers,verbs=update
 
 func (r *ConnectionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-
 	var obj postgresv1alpha1.Connection
 	if err := r.Get(ctx, req.NamespacedName, &obj); err != nil {
+		// the connection is gone, so its pools are not needed anymore
+		if errors.IsNotFound(err) && r.Pools != nil {
+			r.Pools.Evict(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	// TODO
-	// _, err := connection.GetConnection(ctx, r.Client, obj)
-	// if err != nil {
-	//	return ctrl.Result{}, err
-	// }
+	probeInterval := r.ProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = DefaultProbeInterval
+	}
+
+	// a failed probe is not a reconciliation error, it is reported within the status instead
+	err := connection.Probe(ctx, r.Client, &obj)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to probe connection",
+			"connection", obj.ObjectMeta.Name,
+			"namespace", obj.ObjectMeta.Namespace,
+		)
+	}
+
+	if err = r.Status().Update(ctx, &obj); err != nil {
+		log.FromContext(ctx).Error(err, "failed to update connection status",
+			"connection", obj.ObjectMeta.Name,
+			"namespace", obj.ObjectMeta.Namespace,
+		)
+		return ctrl.Result{}, err
+	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: probeInterval}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Status updates don't change the generation of a
+// connection, so every probe only schedules the next one after the probe interval instead of probing again at once.
 func (r *ConnectionReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&postgresv1alpha1.Connection{}).
+		For(&postgresv1alpha1.Connection{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -2,12 +2,16 @@ package controllers
 
 import (
 	"context"
+	"github.com/orbatschow/kubepost/pkg/connection"
 	"github.com/orbatschow/kubepost/pkg/database"
-	"github.com/orbatschow/kubepost/pkg/extension"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 )
@@ -16,6 +20,7 @@ import (
 type DatabaseReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Pools  *connection.Manager
 }
 
 // +kubebuilder:rbac:groups=postgres.kubepost.io,resources=databases,verbs=get;list;watch;create;update;patch;delete
@@ -34,25 +39,20 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	_, connections, err := database.Reconcile(ctx, r.Client, &obj)
-	if err != nil {
-		log.FromContext(ctx).Error(err, "failed to reconcile database",
-			"database", obj.ObjectMeta.Name,
-			"namespace", obj.ObjectMeta.Namespace,
-		)
-		return ctrl.Result{}, err
-	}
+	previous := obj.Status.DeepCopy()
+	reconciled, err := database.Reconcile(ctx, r.Client, r.Pools, &obj)
 
-	if connections == nil {
-		return ctrl.Result{}, nil
+	// the database is nil, if it is about to be deleted or could not be reconciled at all
+	if reconciled != nil && databaseStatusChanged(previous, &reconciled.Status) {
+		if statusErr := r.Status().Update(ctx, reconciled); statusErr != nil {
+			log.FromContext(ctx).Error(statusErr, "failed to update database status",
+				"database", obj.ObjectMeta.Name,
+				"namespace", obj.ObjectMeta.Namespace,
+			)
+			return ctrl.Result{}, statusErr
+		}
 	}
 
-	// skip everything else, if deletion is scheduled
-	if !obj.ObjectMeta.DeletionTimestamp.IsZero() {
-		return ctrl.Result{}, nil
-	}
-
-	err = extension.Reconcile(ctx, r.Client, connections, &obj)
 	if err != nil {
 		log.FromContext(ctx).Error(err, "failed to reconcile database",
 			"database", obj.ObjectMeta.Name,
@@ -64,9 +64,23 @@ func (r *DatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Status updates don't change the generation of a
+// database, so they don't cause another reconciliation.
 func (r *DatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.Database{}).
+		For(&v1alpha1.Database{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
+
+// databaseStatusChanged checks whether the status of a database differs in more than the times of the last
+// reconciliation.
+func databaseStatusChanged(previous *v1alpha1.DatabaseStatus, current *v1alpha1.DatabaseStatus) bool {
+	previous, current = previous.DeepCopy(), current.DeepCopy()
+	for i := range previous.Connections {
+		previous.Connections[i].LastReconcileTime = metav1.Time{}
+	}
+	for i := range current.Connections {
+		current.Connections[i].LastReconcileTime = metav1.Time{}
+	}
+	return !equality.Semantic.DeepEqual(previous, current)
+}
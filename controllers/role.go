@@ -2,18 +2,32 @@ package controllers
 
 import (
 	"context"
+	"time"
+
 	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/connection"
 	"github.com/orbatschow/kubepost/pkg/role"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // RoleReconciler reconciles a Role object
 type RoleReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	Pools  *connection.Manager
 }
 
 // +kubebuilder:rbac:groups=postgres.kubepost.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
@@ -27,21 +41,119 @@ func (r *RoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	_, err := role.Reconcile(ctx, r.Client, &obj)
+	previous := obj.Status.DeepCopy()
+	reconciled, err := role.Reconcile(ctx, r.Client, r.Pools, &obj)
+
+	// the role is nil, if it is about to be deleted or could not be reconciled at all
+	if reconciled != nil && roleStatusChanged(previous, &reconciled.Status) {
+		if statusErr := r.Status().Update(ctx, reconciled); statusErr != nil {
+			log.FromContext(ctx).Error(statusErr, "failed to update role status",
+				"role", obj.ObjectMeta.Name,
+				"namespace", obj.ObjectMeta.Namespace,
+			)
+			return ctrl.Result{}, statusErr
+		}
+	}
+
 	if err != nil {
 		log.FromContext(ctx).Error(err, "failed to reconcile role",
-			"database", obj.ObjectMeta.Name,
+			"role", obj.ObjectMeta.Name,
 			"namespace", obj.ObjectMeta.Namespace,
 		)
 		return ctrl.Result{}, err
 	}
 
+	if reconciled == nil {
+		return ctrl.Result{}, nil
+	}
+
+	// requeue the role, once its password is due for rotation
+	if next := reconciled.Status.NextPasswordRotation; next != nil {
+		requeueAfter := time.Until(next.Time)
+		if requeueAfter < time.Second {
+			requeueAfter = time.Second
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager. Status updates don't change the generation of a role,
+// so they don't cause another reconciliation. Changes of secrets and connections are watched separately.
 func (r *RoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.Role{}).
+		For(&v1alpha1.Role{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&v1.Secret{}).
+		Watches(
+			&source.Kind{Type: &v1alpha1.Connection{}},
+			handler.EnqueueRequestsFromMapFunc(r.findRolesForConnection),
+			// probes only update the status of a connection, which does not affect its roles
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		).
+		Watches(
+			&source.Kind{Type: &v1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.findRolesForPasswordSecret),
+		).
 		Complete(r)
 }
+
+// roleStatusChanged checks whether the status of a role differs in more than the times of the last reconciliation.
+func roleStatusChanged(previous *v1alpha1.RoleStatus, current *v1alpha1.RoleStatus) bool {
+	previous, current = previous.DeepCopy(), current.DeepCopy()
+	for i := range previous.Connections {
+		previous.Connections[i].LastReconcileTime = metav1.Time{}
+	}
+	for i := range current.Connections {
+		current.Connections[i].LastReconcileTime = metav1.Time{}
+	}
+	return !equality.Semantic.DeepEqual(previous, current)
+}
+
+// findRolesForConnection enqueues all roles, whose connection selector matches the labels of the connection,
+// so that their connection secrets are rewritten whenever the connection changes.
+func (r *RoleReconciler) findRolesForConnection(obj client.Object) []reconcile.Request {
+	var roles v1alpha1.RoleList
+	if err := r.List(context.Background(), &roles); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range roles.Items {
+		if item.Spec.ConnectionSecret == nil {
+			continue
+		}
+
+		selector, err := metav1.LabelSelectorAsSelector(&item.Spec.ConnectionSelector)
+		if err != nil || !selector.Matches(labels.Set(obj.GetLabels())) {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+		})
+	}
+
+	return requests
+}
+
+// findRolesForPasswordSecret enqueues all roles, that reference the secret as their password.
+func (r *RoleReconciler) findRolesForPasswordSecret(obj client.Object) []reconcile.Request {
+	var roles v1alpha1.RoleList
+	if err := r.List(context.Background(), &roles, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, item := range roles.Items {
+		if item.Spec.Password == nil || item.Spec.Password.Name != obj.GetName() {
+			continue
+		}
+
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+		})
+	}
+
+	return requests
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -17,6 +18,7 @@ import (
 
 	postgresv1alpha1 "github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/controllers"
+	"github.com/orbatschow/kubepost/pkg/connection"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -40,9 +42,18 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var productionLogger bool
+	var connectionProbeInterval time.Duration
+	var connectionPoolIdleTimeout time.Duration
+	var connectionPoolMaxConnections int
 	flag.BoolVar(&productionLogger, "production-logger", true, "configures the internal logger")
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.DurationVar(&connectionProbeInterval, "connection-probe-interval", controllers.DefaultProbeInterval,
+		"The interval in which the PostgreSQL servers of all connections are probed.")
+	flag.DurationVar(&connectionPoolIdleTimeout, "connection-pool-idle-timeout", connection.DefaultIdleTimeout,
+		"The duration after which unused connection pools are closed.")
+	flag.IntVar(&connectionPoolMaxConnections, "connection-pool-max-connections", connection.DefaultMaxConnections,
+		"The maximum number of connections per connection pool.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -78,16 +89,26 @@ func main() {
 		os.Exit(1)
 	}
 
+	// the pools are shared between all controllers and closed, when the manager shuts down
+	pools := connection.NewManager(connectionPoolIdleTimeout, int32(connectionPoolMaxConnections))
+	if err = mgr.Add(pools); err != nil {
+		setupLog.Error(err, "unable to set up connection pools")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.RoleReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Pools:  pools,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Role")
 		os.Exit(1)
 	}
 	if err = (&controllers.ConnectionReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Pools:         pools,
+		ProbeInterval: connectionProbeInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Connection")
 		os.Exit(1)
@@ -95,6 +116,7 @@ func main() {
 	if err = (&controllers.DatabaseReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
+		Pools:  pools,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Database")
 		os.Exit(1)
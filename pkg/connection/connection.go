@@ -4,8 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
-	"github.com/jackc/pgx/v4"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/pkg/namespace"
 	v1 "k8s.io/api/core/v1"
@@ -16,6 +16,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// ConnectTimeout limits the duration of dialing a PostgreSQL server, so that unreachable servers don't block
+// the reconciliation.
+const ConnectTimeout = 10 * time.Second
+
 func List(ctx context.Context, ctrlClient client.Client, connectionNamespaceSelector metav1.LabelSelector, connectionSelector metav1.LabelSelector) ([]v1alpha1.Connection, error) {
 	namespaces, err := namespace.List(ctx, ctrlClient, connectionNamespaceSelector)
 	if err != nil {
@@ -43,7 +47,9 @@ func List(ctx context.Context, ctrlClient client.Client, connectionNamespaceSele
 	return connections, nil
 }
 
-func GetConnection(ctx context.Context, client client.Client, connection *v1alpha1.Connection) (*pgx.Conn, error) {
+// getConnectionString resolves the credentials of the given connection and returns the
+// connection string, that can be used to connect to the PostgreSQL server, as well as the username.
+func getConnectionString(ctx context.Context, client client.Client, connection *v1alpha1.Connection) (string, string, error) {
 	usernameRef := types.NamespacedName{
 		Namespace: connection.ObjectMeta.Namespace,
 		Name:      connection.Spec.Username.Name,
@@ -51,43 +57,39 @@ func GetConnection(ctx context.Context, client client.Client, connection *v1alph
 
 	passwordRef := types.NamespacedName{
 		Namespace: connection.ObjectMeta.Namespace,
-		Name:      connection.Spec.Username.Name,
+		Name:      connection.Spec.Password.Name,
 	}
 
 	var usernameSecret v1.Secret
 	if err := client.Get(ctx, usernameRef, &usernameSecret); err != nil {
-		return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, usernameSecret.Name)
+		return "", "", errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, usernameRef.Name)
 	}
 
 	var passwordSecret v1.Secret
 	if err := client.Get(ctx, passwordRef, &passwordSecret); err != nil {
-		return nil, errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, passwordSecret.Name)
+		return "", "", errors.NewNotFound(schema.GroupResource{Resource: "secrets"}, passwordRef.Name)
 	}
 
 	usernameBytes := usernameSecret.Data[connection.Spec.Username.Key]
 	if usernameBytes == nil {
-		return nil, fmt.Errorf("could not parse username for connection '%s/%s' from secret '%s/%s", connection.ObjectMeta.Namespace, connection.ObjectMeta.Name, connection.ObjectMeta.Namespace, usernameSecret.ObjectMeta.Name)
+		return "", "", fmt.Errorf("could not parse username for connection '%s/%s' from secret '%s/%s", connection.ObjectMeta.Namespace, connection.ObjectMeta.Name, connection.ObjectMeta.Namespace, usernameSecret.ObjectMeta.Name)
 	}
 	username := string(usernameBytes)
 
 	passwordBytes := passwordSecret.Data[connection.Spec.Password.Key]
 	if passwordBytes == nil {
-		return nil, fmt.Errorf("could not parse password for connection '%s/%s' from secret '%s/%s", connection.ObjectMeta.Namespace, connection.ObjectMeta.Name, connection.ObjectMeta.Namespace, usernameSecret.ObjectMeta.Name)
+		return "", "", fmt.Errorf("could not parse password for connection '%s/%s' from secret '%s/%s", connection.ObjectMeta.Namespace, connection.ObjectMeta.Name, connection.ObjectMeta.Namespace, passwordSecret.ObjectMeta.Name)
 	}
 	password := string(passwordBytes)
 
-	conn, err := pgx.Connect(context.Background(), fmt.Sprintf(
+	connectionString := fmt.Sprintf(
 		"postgres://%s@%s:%d/%s?sslmode=%s&application_name=kubepost",
 		url.UserPassword(username, password).String(),
 		connection.Spec.Host,
 		connection.Spec.Port,
 		url.PathEscape(connection.Spec.Database),
 		connection.Spec.SSLMode,
-	),
 	)
-	if err != nil {
-		return nil, fmt.Errorf("unable to connect to database: '%s' on host '%s' with user '%s' : '%s'", connection.Spec.Database, connection.Spec.Host, username, err)
-	}
 
-	return conn, nil
+	return connectionString, username, nil
 }
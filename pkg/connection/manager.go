@@ -0,0 +1,221 @@
+package connection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DefaultIdleTimeout is the duration after which unused pools are closed, if no other timeout is configured.
+	DefaultIdleTimeout = 10 * time.Minute
+	// DefaultMaxConnections is the maximum number of connections per pool, if no other limit is configured.
+	DefaultMaxConnections = 4
+)
+
+// Manager keeps a connection pool per connection and database, so that the PostgreSQL servers
+// are not dialed on every reconciliation. Pools are rebuilt whenever the connection spec or its
+// credentials change and closed after they have not been used for the configured idle timeout.
+type Manager struct {
+	// IdleTimeout defines after which duration an unused pool is closed.
+	IdleTimeout time.Duration
+	// MaxConnections defines the maximum number of connections per pool.
+	MaxConnections int32
+
+	mutex sync.Mutex
+	pools map[poolKey]*poolEntry
+}
+
+type poolKey struct {
+	uid      types.UID
+	database string
+}
+
+type poolEntry struct {
+	pool        *pgxpool.Pool
+	connection  types.NamespacedName
+	fingerprint string
+	lastUsed    time.Time
+}
+
+func NewManager(idleTimeout time.Duration, maxConnections int32) *Manager {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	if maxConnections <= 0 {
+		maxConnections = DefaultMaxConnections
+	}
+
+	return &Manager{
+		IdleTimeout:    idleTimeout,
+		MaxConnections: maxConnections,
+		pools:          map[poolKey]*poolEntry{},
+	}
+}
+
+// GetPool returns the pool for the given connection and database. If the database is empty, the
+// database of the connection spec is used.
+func (m *Manager) GetPool(ctx context.Context, ctrlClient client.Client, connection *v1alpha1.Connection, database string) (*pgxpool.Pool, error) {
+	if database == "" {
+		database = connection.Spec.Database
+	}
+
+	// work on a copy, so that the database switch is not visible to the caller
+	target := connection.DeepCopy()
+	target.Spec.Database = database
+
+	connectionString, username, err := getConnectionString(ctx, ctrlClient, target)
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256([]byte(connectionString))
+	fingerprint := hex.EncodeToString(checksum[:])
+
+	key := poolKey{
+		uid:      connection.ObjectMeta.UID,
+		database: database,
+	}
+
+	m.mutex.Lock()
+	entry, ok := m.pools[key]
+	if ok && entry.fingerprint == fingerprint {
+		entry.lastUsed = time.Now()
+		m.mutex.Unlock()
+		return entry.pool, nil
+	}
+	m.mutex.Unlock()
+
+	config, err := pgxpool.ParseConfig(connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse connection string for connection '%s/%s': '%w'", connection.ObjectMeta.Namespace, connection.ObjectMeta.Name, err)
+	}
+	config.MaxConns = m.MaxConnections
+	config.MaxConnIdleTime = m.IdleTimeout
+	config.ConnConfig.ConnectTimeout = ConnectTimeout
+
+	// the server is dialed without holding the lock, so that a slow or unreachable server does not block the
+	// pools of all other connections
+	pool, err := pgxpool.ConnectConfig(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to database: '%s' on host '%s' with user '%s' : '%w'", database, connection.Spec.Host, username, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// another reconciliation might have created the same pool in the meantime
+	entry, ok = m.pools[key]
+	if ok && entry.fingerprint == fingerprint {
+		entry.lastUsed = time.Now()
+		go pool.Close()
+		return entry.pool, nil
+	}
+
+	// the connection spec or its credentials changed, the existing pool has to be rebuilt
+	if ok {
+		log.FromContext(ctx).Info("connection changed, rebuilding pool",
+			"connection", entry.connection,
+			"database", database,
+		)
+		go entry.pool.Close()
+	}
+
+	m.pools[key] = &poolEntry{
+		pool: pool,
+		connection: types.NamespacedName{
+			Namespace: connection.ObjectMeta.Namespace,
+			Name:      connection.ObjectMeta.Name,
+		},
+		fingerprint: fingerprint,
+		lastUsed:    time.Now(),
+	}
+
+	return pool, nil
+}
+
+// Evict closes all pools, that belong to the given connection.
+func (m *Manager) Evict(connection types.NamespacedName) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for key, entry := range m.pools {
+		if entry.connection == connection {
+			delete(m.pools, key)
+			go entry.pool.Close()
+		}
+	}
+}
+
+// EvictDatabase closes the pool for the given connection and database and waits until all of its sessions are
+// closed. This is required for statements, that fail while other sessions are connected to the database.
+func (m *Manager) EvictDatabase(connection *v1alpha1.Connection, database string) {
+	key := poolKey{
+		uid:      connection.ObjectMeta.UID,
+		database: database,
+	}
+
+	m.mutex.Lock()
+	entry, ok := m.pools[key]
+	delete(m.pools, key)
+	m.mutex.Unlock()
+
+	if ok {
+		entry.pool.Close()
+	}
+}
+
+// Start periodically closes idle pools until the context is cancelled. Afterwards all pools are closed.
+// It implements the manager.Runnable interface of the controller-runtime.
+func (m *Manager) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.IdleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			m.Close()
+			return nil
+		case <-ticker.C:
+			m.evictIdle(ctx)
+		}
+	}
+}
+
+// Close closes all pools.
+func (m *Manager) Close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for key, entry := range m.pools {
+		delete(m.pools, key)
+		entry.pool.Close()
+	}
+}
+
+func (m *Manager) evictIdle(ctx context.Context) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for key, entry := range m.pools {
+		if time.Since(entry.lastUsed) < m.IdleTimeout {
+			continue
+		}
+
+		log.FromContext(ctx).Info("closing idle pool",
+			"connection", entry.connection,
+			"database", key.database,
+		)
+		delete(m.pools, key)
+		go entry.pool.Close()
+	}
+}
@@ -0,0 +1,127 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	reasonProbeSucceeded         = "ProbeSucceeded"
+	reasonProbeFailed            = "ProbeFailed"
+	reasonCredentialsUnavailable = "CredentialsUnavailable"
+	reasonAuthenticationFailed   = "AuthenticationFailed"
+	reasonConnectionRejected     = "ConnectionRejected"
+	reasonUnreachable            = "Unreachable"
+	reasonUnknown                = "Unknown"
+)
+
+// Probe opens a connection to the PostgreSQL server of the given connection, runs a probe query and
+// records the outcome within the status of the connection. The returned error is the error the probe ran into.
+func Probe(ctx context.Context, ctrlClient client.Client, connection *v1alpha1.Connection) error {
+	log.FromContext(ctx).Info("probing connection", "connection", connection.ObjectMeta.Name)
+
+	now := metav1.Now()
+	connection.Status.LastProbeTime = &now
+
+	// the version is only reported for servers, that answered the current probe
+	connection.Status.ServerVersion = ""
+
+	ctx, cancel := context.WithTimeout(ctx, ConnectTimeout)
+	defer cancel()
+
+	connectionString, _, err := getConnectionString(ctx, ctrlClient, connection)
+	if err != nil {
+		setProbeConditions(connection, metav1.ConditionUnknown, reasonUnknown, metav1.ConditionFalse, reasonCredentialsUnavailable, err)
+		return err
+	}
+
+	conn, err := pgx.Connect(ctx, connectionString)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		// the server answered, but refused the credentials
+		case errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "28"):
+			setProbeConditions(connection, metav1.ConditionTrue, reasonProbeSucceeded, metav1.ConditionFalse, reasonAuthenticationFailed, err)
+		// the server answered, but refused the connection for another reason, e.g. a missing database
+		case errors.As(err, &pgErr):
+			setProbeConditions(connection, metav1.ConditionTrue, reasonProbeSucceeded, metav1.ConditionFalse, reasonConnectionRejected, err)
+		default:
+			setProbeConditions(connection, metav1.ConditionFalse, reasonUnreachable, metav1.ConditionUnknown, reasonUnknown, err)
+		}
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var version string
+	err = conn.QueryRow(ctx, "SELECT current_setting('server_version')").Scan(&version)
+	if err != nil {
+		setProbeConditions(connection, metav1.ConditionTrue, reasonProbeSucceeded, metav1.ConditionTrue, reasonProbeSucceeded, err)
+		return err
+	}
+
+	connection.Status.ServerVersion = version
+	setProbeConditions(connection, metav1.ConditionTrue, reasonProbeSucceeded, metav1.ConditionTrue, reasonProbeSucceeded, nil)
+
+	return nil
+}
+
+func setProbeConditions(
+	connection *v1alpha1.Connection,
+	reachable metav1.ConditionStatus,
+	reachableReason string,
+	authenticated metav1.ConditionStatus,
+	authenticatedReason string,
+	err error,
+) {
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	connection.Status.Error = message
+
+	meta.SetStatusCondition(&connection.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReachable,
+		Status:             reachable,
+		ObservedGeneration: connection.ObjectMeta.Generation,
+		Reason:             reachableReason,
+		Message:            conditionMessage(reachable, message),
+	})
+
+	meta.SetStatusCondition(&connection.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionAuthenticated,
+		Status:             authenticated,
+		ObservedGeneration: connection.ObjectMeta.Generation,
+		Reason:             authenticatedReason,
+		Message:            conditionMessage(authenticated, message),
+	})
+
+	ready := metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: connection.ObjectMeta.Generation,
+		Reason:             reasonProbeSucceeded,
+	}
+	if err != nil {
+		ready.Status = metav1.ConditionFalse
+		ready.Reason = reasonProbeFailed
+		ready.Message = message
+	}
+	meta.SetStatusCondition(&connection.Status.Conditions, ready)
+}
+
+// conditionMessage only attaches the error message to conditions, that are not fulfilled.
+func conditionMessage(status metav1.ConditionStatus, message string) string {
+	if status == metav1.ConditionTrue {
+		return ""
+	}
+	return message
+}
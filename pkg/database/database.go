@@ -2,9 +2,18 @@ package database
 
 import (
 	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/pkg/connection"
+	"github.com/orbatschow/kubepost/pkg/extension"
+	"github.com/orbatschow/kubepost/pkg/schema"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -13,14 +22,35 @@ import (
 
 var Finalizer = "finalizer.postgres.kubepost.io/database"
 
-func Reconcile(ctx context.Context, ctrlClient client.Client, db *v1alpha1.Database) (*v1alpha1.Database, []v1alpha1.Connection, error) {
+const (
+	reasonReconciled      = "Reconciled"
+	reasonReconcileFailed = "ReconcileFailed"
+	reasonNoConnections   = "NoConnections"
+)
+
+func Reconcile(ctx context.Context, ctrlClient client.Client, pools *connection.Manager, db *v1alpha1.Database) (*v1alpha1.Database, error) {
 
 	connections, err := connection.List(ctx, ctrlClient, db.Spec.ConnectionNamespaceSelector, db.Spec.ConnectionSelector)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	// the finalizer is added before the status is computed, as updating the database replaces its status with the
+	// one stored by the API server
+	if db.ObjectMeta.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(db, Finalizer) {
+		log.FromContext(ctx).Info("updating finalizers")
+
+		controllerutil.AddFinalizer(db, Finalizer)
+		if err = ctrlClient.Update(ctx, db); err != nil {
+			return nil, err
+		}
 	}
 
-	for _, postgres := range connections {
+	var errs []error
+	var results []v1alpha1.DatabaseConnectionStatus
+	for i := range connections {
+		postgres := &connections[i]
+
 		log.FromContext(ctx).Info(
 			"reconciling database",
 			"connection", types.NamespacedName{
@@ -29,62 +59,172 @@ func Reconcile(ctx context.Context, ctrlClient client.Client, db *v1alpha1.Datab
 			},
 		)
 
-		conn, err := connection.GetConnection(ctx, ctrlClient, &postgres)
+		status := v1alpha1.DatabaseConnectionStatus{
+			Namespace: postgres.ObjectMeta.Namespace,
+			Name:      postgres.ObjectMeta.Name,
+			Phase:     v1alpha1.PhaseReady,
+		}
+
+		err = reconcileConnection(ctx, ctrlClient, pools, db, postgres, &status)
+
+		// skip everything else, if deletion is scheduled
+		if !db.ObjectMeta.DeletionTimestamp.IsZero() {
+			return nil, err
+		}
+
 		if err != nil {
 			log.FromContext(ctx).Error(
 				err,
-				"failed to establish a connection",
+				"failed to reconcile database",
 				"connection", types.NamespacedName{
 					Namespace: postgres.ObjectMeta.Namespace,
 					Name:      postgres.ObjectMeta.Name,
 				},
 			)
-			continue
+			errs = append(errs, err)
+			setConnectionError(&status, err)
 		}
 
-		repository := Repository{
-			database:   db,
-			connection: &postgres,
-			conn:       conn,
-		}
+		status.LastReconcileTime = metav1.Now()
+		results = append(results, status)
+	}
 
-		err = repository.handleFinalizer(ctx, ctrlClient)
-		if err != nil {
-			return nil, nil, err
-		}
+	db.Status.ObservedGeneration = db.ObjectMeta.Generation
+	db.Status.Connections = results
+	db.Status.ReadyConnections = fmt.Sprintf("%d/%d", len(connections)-len(errs), len(connections))
 
-		// skip everything else, if deletion is scheduled
-		if !repository.database.ObjectMeta.DeletionTimestamp.IsZero() {
-			return nil, nil, nil
-		}
+	// the name is only tracked once the database has been renamed on all connections
+	if len(errs) == 0 {
+		db.Status.Name = db.PostgresName()
+	}
+	db.Status.ManagedSchemas = schema.GetManagedSchemas(db, len(errs) == 0)
 
-		exists, err := repository.Exists(ctx)
-		if err != nil {
-			return nil, nil, err
-		}
+	switch {
+	case len(errs) > 0:
+		setReadyCondition(db, metav1.ConditionFalse, reasonReconcileFailed, utilerrors.NewAggregate(errs).Error())
+	case len(connections) == 0:
+		setReadyCondition(db, metav1.ConditionFalse, reasonNoConnections, "no connection matches the selectors of the database")
+	default:
+		setReadyCondition(db, metav1.ConditionTrue, reasonReconciled, "database has been reconciled on all connections")
+	}
 
-		if exists == true {
-			log.FromContext(ctx).Info(
-				"database exists, skipping creation",
-				"connection", types.NamespacedName{
-					Namespace: postgres.ObjectMeta.Namespace,
-					Name:      postgres.ObjectMeta.Name,
-				},
-			)
-		} else {
-			err = repository.Create(ctx)
-			if err != nil {
-				return nil, nil, err
-			}
-		}
+	return db, utilerrors.NewAggregate(errs)
+}
 
-		err = repository.AlterOwner(ctx)
+// reconcileConnection reconciles the database and its extensions on a single connection and records the observed
+// state within the given status. If deletion of the database is scheduled, only the finalizer is handled.
+func reconcileConnection(
+	ctx context.Context,
+	ctrlClient client.Client,
+	pools *connection.Manager,
+	db *v1alpha1.Database,
+	postgres *v1alpha1.Connection,
+	status *v1alpha1.DatabaseConnectionStatus,
+) error {
+	conn, err := pools.GetPool(ctx, ctrlClient, postgres, "")
+	if err != nil {
+		return err
+	}
+
+	repository := Repository{
+		database:   db,
+		connection: postgres,
+		conn:       conn,
+		pools:      pools,
+	}
+
+	err = repository.handleFinalizer(ctx, ctrlClient)
+	if err != nil {
+		return err
+	}
+
+	if !repository.database.ObjectMeta.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	err = repository.ReconcileName(ctx)
+	if err != nil {
+		return err
+	}
+
+	exists, err := repository.Exists(ctx)
+	if err != nil {
+		return err
+	}
+
+	if exists == true {
+		log.FromContext(ctx).Info(
+			"database exists, skipping creation",
+			"connection", types.NamespacedName{
+				Namespace: postgres.ObjectMeta.Namespace,
+				Name:      postgres.ObjectMeta.Name,
+			},
+		)
+	} else {
+		err = repository.Create(ctx)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
 	}
+	status.Exists = true
+
+	err = repository.AlterOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	status.Owner, err = repository.GetOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	status.Mismatches, err = repository.ReconcileParameters(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = repository.ReconcileSettings(ctx)
+	if err != nil {
+		return err
+	}
 
-	return db, connections, nil
+	// extensions and schemas can only be managed from within the database
+	if !db.Spec.AllowConnections {
+		log.FromContext(ctx).Info("connections to the database are not allowed, skipping extensions and schemas")
+		return nil
+	}
+
+	status.Extensions, err = extension.Reconcile(ctx, ctrlClient, pools, postgres, db)
+	if err != nil {
+		return err
+	}
+
+	return schema.Reconcile(ctx, ctrlClient, pools, postgres, db)
+}
+
+// setConnectionError records the given error within the status of the connection.
+func setConnectionError(status *v1alpha1.DatabaseConnectionStatus, err error) {
+	status.Phase = v1alpha1.PhaseFailed
+	status.Message = err.Error()
+
+	var repositoryErr *RepositoryError
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &repositoryErr):
+		status.SQLState = repositoryErr.PostgresErrorCode
+	case errors.As(err, &pgErr):
+		status.SQLState = pgErr.Code
+	}
+}
+
+func setReadyCondition(db *v1alpha1.Database, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&db.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: db.ObjectMeta.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
 }
 
 func (r *Repository) handleFinalizer(ctx context.Context, ctrClient client.Client) error {
@@ -147,21 +287,6 @@ func (r *Repository) handleFinalizer(ctx context.Context, ctrClient client.Clien
 				Name:      r.connection.ObjectMeta.Name,
 			},
 		)
-
-	// add finalizer to the object.
-	case r.database.ObjectMeta.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(r.database, Finalizer):
-		log.FromContext(ctx).Info(
-			"updating finalizers",
-			"connection", types.NamespacedName{
-				Namespace: r.connection.ObjectMeta.Namespace,
-				Name:      r.connection.ObjectMeta.Name,
-			},
-		)
-
-		controllerutil.AddFinalizer(r.database, Finalizer)
-		if err := ctrClient.Update(ctx, r.database); err != nil {
-			return err
-		}
 	}
 
 	return nil
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+)
+
+func TestSetConnectionError(t *testing.T) {
+	pgErr := &pgconn.PgError{Severity: "ERROR", Code: "2BP01", Message: "cannot drop schema app because other objects depend on it"}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "plain error", err: errors.New("connection refused"), want: ""},
+		{name: "postgres error", err: pgErr, want: "2BP01"},
+		{name: "wrapped postgres error", err: fmt.Errorf("unable to drop schema: %w", pgErr), want: "2BP01"},
+		{name: "repository error", err: &RepositoryError{Message: "exists", PostgresErrorCode: "42P04"}, want: "42P04"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var status v1alpha1.DatabaseConnectionStatus
+			setConnectionError(&status, test.err)
+
+			if status.Phase != v1alpha1.PhaseFailed || status.Message != test.err.Error() || status.SQLState != test.want {
+				t.Errorf("setConnectionError() = %+v, want SQLSTATE %q", status, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	LocaleProviderLibc = "libc"
+	LocaleProviderICU  = "icu"
+)
+
+// Parameters describe the creation parameters of a PostgreSQL database, as stored within pg_database.
+type Parameters struct {
+	Encoding         string
+	LCCollate        string
+	LCCtype          string
+	LocaleProvider   string
+	ICULocale        string
+	Tablespace       string
+	ConnectionLimit  int32
+	AllowConnections bool
+	IsTemplate       bool
+}
+
+// getCreateOptions returns the options, that are passed to CREATE DATABASE.
+func (r *Repository) getCreateOptions() []string {
+	spec := r.database.Spec
+
+	var options []string
+	if spec.Template != "" {
+		options = append(options, fmt.Sprintf("TEMPLATE %s", postgres.SanitizeString(spec.Template)))
+	}
+	if spec.Encoding != "" {
+		options = append(options, fmt.Sprintf("ENCODING %s", postgres.SanitizeLiteral(spec.Encoding)))
+	}
+	if spec.LocaleProvider != "" {
+		options = append(options, fmt.Sprintf("LOCALE_PROVIDER %s", postgres.SanitizeLiteral(spec.LocaleProvider)))
+	}
+	if spec.LCCollate != "" {
+		options = append(options, fmt.Sprintf("LC_COLLATE %s", postgres.SanitizeLiteral(spec.LCCollate)))
+	}
+	if spec.LCCtype != "" {
+		options = append(options, fmt.Sprintf("LC_CTYPE %s", postgres.SanitizeLiteral(spec.LCCtype)))
+	}
+	if spec.ICULocale != "" {
+		options = append(options, fmt.Sprintf("ICU_LOCALE %s", postgres.SanitizeLiteral(spec.ICULocale)))
+	}
+	if spec.Tablespace != "" {
+		options = append(options, fmt.Sprintf("TABLESPACE %s", postgres.SanitizeString(spec.Tablespace)))
+	}
+
+	options = append(options,
+		fmt.Sprintf("ALLOW_CONNECTIONS %t", spec.AllowConnections),
+		fmt.Sprintf("CONNECTION LIMIT %d", spec.ConnectionLimit),
+		fmt.Sprintf("IS_TEMPLATE %t", spec.IsTemplate),
+	)
+
+	return options
+}
+
+// ReconcileParameters alters the parameters of the database, that can be changed after the database has been
+// created. The immutable parameters, that differ from the desired ones, are returned.
+func (r *Repository) ReconcileParameters(ctx context.Context) ([]string, error) {
+	current, err := r.GetParameters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := r.getImmutableMismatches(current)
+	for _, mismatch := range mismatches {
+		log.FromContext(ctx).Info("immutable database parameter differs", "mismatch", mismatch)
+	}
+
+	spec := r.database.Spec
+	name := postgres.SanitizeString(r.database.PostgresName())
+
+	var clauses []string
+	if spec.AllowConnections != current.AllowConnections {
+		clauses = append(clauses, fmt.Sprintf("ALLOW_CONNECTIONS %t", spec.AllowConnections))
+	}
+	if spec.ConnectionLimit != current.ConnectionLimit {
+		clauses = append(clauses, fmt.Sprintf("CONNECTION LIMIT %d", spec.ConnectionLimit))
+	}
+	if spec.IsTemplate != current.IsTemplate {
+		clauses = append(clauses, fmt.Sprintf("IS_TEMPLATE %t", spec.IsTemplate))
+	}
+
+	if len(clauses) > 0 {
+		query := fmt.Sprintf("ALTER DATABASE %s WITH %s", name, strings.Join(clauses, " "))
+		log.FromContext(ctx).Info("computed alter database query", "query", query)
+
+		if _, err = r.conn.Exec(ctx, query); err != nil {
+			return mismatches, r.newRepositoryError(err)
+		}
+	}
+
+	if spec.Tablespace != "" && spec.Tablespace != current.Tablespace {
+		// moving a database fails, as long as kubepost itself is connected to it
+		r.pools.EvictDatabase(r.connection, r.database.PostgresName())
+
+		query := fmt.Sprintf("ALTER DATABASE %s SET TABLESPACE %s", name, postgres.SanitizeString(spec.Tablespace))
+		log.FromContext(ctx).Info("computed alter database query", "query", query)
+
+		if _, err = r.conn.Exec(ctx, query); err != nil {
+			return mismatches, r.newRepositoryError(err)
+		}
+	}
+
+	return mismatches, nil
+}
+
+func (r *Repository) GetParameters(ctx context.Context) (*Parameters, error) {
+	version, err := postgres.GetServerVersionNum(ctx, r.conn)
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	// the locale provider has been introduced with PostgreSQL 15, the ICU locale has been renamed with PostgreSQL 17
+	localeColumns := "'c', NULL"
+	switch {
+	case version >= 170000:
+		localeColumns = "d.datlocprovider::text, d.datlocale"
+	case version >= 150000:
+		localeColumns = "d.datlocprovider::text, d.daticulocale"
+	}
+
+	var parameters Parameters
+	var localeProvider string
+	var icuLocale *string
+	err = r.conn.QueryRow(
+		ctx,
+		fmt.Sprintf(`SELECT
+		pg_encoding_to_char(d.encoding),
+		d.datcollate,
+		d.datctype,
+		%s,
+		t.spcname,
+		d.datconnlimit,
+		d.datallowconn,
+		d.datistemplate
+		FROM pg_catalog.pg_database AS d
+		JOIN pg_catalog.pg_tablespace AS t ON t.oid = d.dattablespace
+		WHERE d.datname = $1`, localeColumns),
+		r.database.PostgresName(),
+	).Scan(
+		&parameters.Encoding,
+		&parameters.LCCollate,
+		&parameters.LCCtype,
+		&localeProvider,
+		&icuLocale,
+		&parameters.Tablespace,
+		&parameters.ConnectionLimit,
+		&parameters.AllowConnections,
+		&parameters.IsTemplate,
+	)
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	switch localeProvider {
+	case "i":
+		parameters.LocaleProvider = LocaleProviderICU
+	default:
+		parameters.LocaleProvider = LocaleProviderLibc
+	}
+
+	if icuLocale != nil {
+		parameters.ICULocale = *icuLocale
+	}
+
+	return &parameters, nil
+}
+
+// getImmutableMismatches compares the parameters, that can only be set when the database is created. Parameters,
+// that are not specified, are not compared.
+func (r *Repository) getImmutableMismatches(current *Parameters) []string {
+	spec := r.database.Spec
+
+	var mismatches []string
+	mismatch := func(parameter string, desired string, current string) {
+		mismatches = append(mismatches, fmt.Sprintf("%s: desired '%s', current '%s'", parameter, desired, current))
+	}
+
+	if spec.Encoding != "" && normalizeEncoding(spec.Encoding) != normalizeEncoding(current.Encoding) {
+		mismatch("encoding", spec.Encoding, current.Encoding)
+	}
+	if spec.LCCollate != "" && spec.LCCollate != current.LCCollate {
+		mismatch("lcCollate", spec.LCCollate, current.LCCollate)
+	}
+	if spec.LCCtype != "" && spec.LCCtype != current.LCCtype {
+		mismatch("lcCtype", spec.LCCtype, current.LCCtype)
+	}
+	if spec.LocaleProvider != "" && spec.LocaleProvider != current.LocaleProvider {
+		mismatch("localeProvider", spec.LocaleProvider, current.LocaleProvider)
+	}
+	if spec.ICULocale != "" && spec.ICULocale != current.ICULocale {
+		mismatch("icuLocale", spec.ICULocale, current.ICULocale)
+	}
+
+	return mismatches
+}
+
+// normalizeEncoding normalizes the name of an encoding like PostgreSQL does, e.g. "utf-8" becomes "UTF8".
+func normalizeEncoding(encoding string) string {
+	return strings.Map(func(char rune) rune {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) {
+			return -1
+		}
+		return unicode.ToUpper(char)
+	}, encoding)
+}
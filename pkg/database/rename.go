@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcileName renames the database, if its name changed since the last successful reconciliation. The database
+// is only renamed, if it still exists with its previous name and no other database with the new name exists.
+func (r *Repository) ReconcileName(ctx context.Context) error {
+	previousName := r.database.Status.Name
+	name := r.database.PostgresName()
+	if previousName == "" || previousName == name {
+		return nil
+	}
+
+	var previousExists, exists bool
+	err := r.conn.QueryRow(
+		ctx,
+		`SELECT
+		EXISTS (SELECT 1 FROM pg_catalog.pg_database WHERE datname = $1),
+		EXISTS (SELECT 1 FROM pg_catalog.pg_database WHERE datname = $2)`,
+		previousName,
+		name,
+	).Scan(&previousExists, &exists)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+
+	// the database has already been renamed or never existed, so it will be created with its new name
+	if !previousExists {
+		return nil
+	}
+
+	if exists {
+		return fmt.Errorf(
+			"could not rename database '%s' to '%s', a database with the new name already exists",
+			previousName,
+			name,
+		)
+	}
+
+	// renaming a database fails, as long as kubepost itself is connected to it
+	r.pools.EvictDatabase(r.connection, previousName)
+
+	_, err = r.conn.Exec(
+		ctx,
+		fmt.Sprintf(
+			"ALTER DATABASE %s RENAME TO %s",
+			postgres.SanitizeString(previousName),
+			postgres.SanitizeString(name),
+		),
+	)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+
+	log.FromContext(ctx).Info("renamed database", "previousName", previousName, "name", name)
+	return nil
+}
@@ -5,17 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/connection"
 	"github.com/orbatschow/kubepost/pkg/postgres"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"strings"
 )
 
 type Repository struct {
 	database   *v1alpha1.Database
 	connection *v1alpha1.Connection
-	conn       *pgx.Conn
+	conn       *pgxpool.Pool
+	pools      *connection.Manager
 }
 
 type RepositoryError struct {
@@ -32,10 +35,30 @@ func (e RepositoryError) Error() string {
 	return e.Message
 }
 
+// newRepositoryError wraps the given error, including the details reported by the PostgreSQL server.
+func (r *Repository) newRepositoryError(err error) *RepositoryError {
+	var pgErr *pgconn.PgError
+	errorCode := ""
+	errorMessage := ""
+	if errors.As(err, &pgErr) {
+		errorCode = pgErr.Code
+		errorMessage = pgErr.Message
+	}
+
+	return &RepositoryError{
+		Database:             r.database.PostgresName(),
+		Connection:           r.connection.ObjectMeta.Name,
+		Namespace:            r.database.ObjectMeta.Namespace,
+		Message:              err.Error(),
+		PostgresErrorCode:    errorCode,
+		PostgresErrorMessage: errorMessage,
+	}
+}
+
 func (r *Repository) Exists(ctx context.Context) (bool, error) {
 	log.FromContext(ctx).Info(
 		"checking if database already exists",
-		"database", r.database.ObjectMeta.Name,
+		"database", r.database.PostgresName(),
 		"connection", r.connection.ObjectMeta.Name,
 		"namespace", r.connection.ObjectMeta.Namespace,
 	)
@@ -44,7 +67,7 @@ func (r *Repository) Exists(ctx context.Context) (bool, error) {
 	err := r.conn.QueryRow(
 		ctx,
 		"SELECT true FROM pg_database WHERE datname = $1",
-		r.database.ObjectMeta.Name,
+		r.database.PostgresName(),
 	).Scan(&exists)
 
 	if err != nil {
@@ -61,7 +84,7 @@ func (r *Repository) Exists(ctx context.Context) (bool, error) {
 		}
 
 		return false, &RepositoryError{
-			Database:             r.database.ObjectMeta.Name,
+			Database:             r.database.PostgresName(),
 			Connection:           r.connection.ObjectMeta.Name,
 			Namespace:            r.database.ObjectMeta.Namespace,
 			Message:              err.Error(),
@@ -77,7 +100,11 @@ func (r *Repository) Create(ctx context.Context) error {
 
 	_, err := r.conn.Exec(
 		ctx,
-		fmt.Sprintf("CREATE DATABASE %s", postgres.SanitizeString(r.database.ObjectMeta.Name)),
+		fmt.Sprintf(
+			"CREATE DATABASE %s WITH %s",
+			postgres.SanitizeString(r.database.PostgresName()),
+			strings.Join(r.getCreateOptions(), " "),
+		),
 	)
 
 	if err != nil {
@@ -90,7 +117,7 @@ func (r *Repository) Create(ctx context.Context) error {
 		}
 
 		return &RepositoryError{
-			Database:             r.database.ObjectMeta.Name,
+			Database:             r.database.PostgresName(),
 			Connection:           r.connection.ObjectMeta.Name,
 			Namespace:            r.database.ObjectMeta.Namespace,
 			Message:              err.Error(),
@@ -110,9 +137,9 @@ func (r *Repository) Create(ctx context.Context) error {
 }
 
 func (r *Repository) Delete(ctx context.Context) *RepositoryError {
-	_, err := r.conn.Query(
+	_, err := r.conn.Exec(
 		ctx,
-		fmt.Sprintf("DROP DATABASE %s WITH (FORCE)", postgres.SanitizeString(r.database.ObjectMeta.Name)),
+		fmt.Sprintf("DROP DATABASE %s WITH (FORCE)", postgres.SanitizeString(r.database.PostgresName())),
 	)
 
 	if err != nil {
@@ -125,7 +152,7 @@ func (r *Repository) Delete(ctx context.Context) *RepositoryError {
 		}
 
 		return &RepositoryError{
-			Database:             r.database.ObjectMeta.Name,
+			Database:             r.database.PostgresName(),
 			Connection:           r.connection.ObjectMeta.Name,
 			Namespace:            r.database.ObjectMeta.Namespace,
 			Message:              err.Error(),
@@ -144,16 +171,14 @@ func (r *Repository) Delete(ctx context.Context) *RepositoryError {
 	return nil
 }
 
-func (r *Repository) AlterOwner(ctx context.Context) error {
+func (r *Repository) GetOwner(ctx context.Context) (string, error) {
 
-	var currentOwner string
+	var owner string
 	err := r.conn.QueryRow(
-		context.Background(),
-		fmt.Sprintf(
-			"select r.rolname from pg_roles as r, pg_database as d where r.oid = d.datdba AND d.datname = '%s'",
-			r.database.ObjectMeta.Name,
-		),
-	).Scan(&currentOwner)
+		ctx,
+		"SELECT r.rolname FROM pg_roles AS r, pg_database AS d WHERE r.oid = d.datdba AND d.datname = $1",
+		r.database.PostgresName(),
+	).Scan(&owner)
 
 	if err != nil {
 		var pgErr *pgconn.PgError
@@ -164,8 +189,8 @@ func (r *Repository) AlterOwner(ctx context.Context) error {
 			errorMessage = pgErr.Message
 		}
 
-		return &RepositoryError{
-			Database:             r.database.ObjectMeta.Name,
+		return "", &RepositoryError{
+			Database:             r.database.PostgresName(),
 			Connection:           r.connection.ObjectMeta.Name,
 			Namespace:            r.database.ObjectMeta.Namespace,
 			Message:              err.Error(),
@@ -174,6 +199,16 @@ func (r *Repository) AlterOwner(ctx context.Context) error {
 		}
 	}
 
+	return owner, nil
+}
+
+func (r *Repository) AlterOwner(ctx context.Context) error {
+
+	currentOwner, err := r.GetOwner(ctx)
+	if err != nil {
+		return err
+	}
+
 	if r.database.Spec.Owner == currentOwner {
 		log.FromContext(ctx).Info(
 			"skipping ownership change",
@@ -186,11 +221,11 @@ func (r *Repository) AlterOwner(ctx context.Context) error {
 		return nil
 	}
 
-	_, err = r.conn.Query(
+	_, err = r.conn.Exec(
 		ctx,
 		fmt.Sprintf(
 			"ALTER DATABASE %s OWNER TO %s",
-			postgres.SanitizeString(r.database.ObjectMeta.Name),
+			postgres.SanitizeString(r.database.PostgresName()),
 			postgres.SanitizeString(r.database.Spec.Owner),
 		),
 	)
@@ -205,7 +240,7 @@ func (r *Repository) AlterOwner(ctx context.Context) error {
 		}
 
 		return &RepositoryError{
-			Database:             r.database.ObjectMeta.Name,
+			Database:             r.database.PostgresName(),
 			Connection:           r.connection.ObjectMeta.Name,
 			Namespace:            r.database.ObjectMeta.Namespace,
 			Message:              err.Error(),
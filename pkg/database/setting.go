@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcileSettings sets the desired settings of the database and resets all settings, that are not desired
+// anymore. Settings, that are unknown to the PostgreSQL server, are skipped and reported as error.
+func (r *Repository) ReconcileSettings(ctx context.Context) error {
+	desiredSettings := map[string]string{}
+	var names []string
+	for name, value := range r.database.Spec.Settings {
+		desiredSettings[strings.ToLower(name)] = value
+		names = append(names, name)
+	}
+
+	invalidSettings, err := postgres.GetInvalidSettings(ctx, r.conn, names)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+	invalid := map[string]bool{}
+	for _, name := range invalidSettings {
+		invalid[strings.ToLower(name)] = true
+	}
+
+	currentSettings, err := r.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	var queries []string
+	for _, name := range postgres.SortedSettingNames(desiredSettings) {
+		current, ok := currentSettings[name]
+		if invalid[name] || ok && postgres.EqualSettingValues(name, desiredSettings[name], current) {
+			continue
+		}
+		queries = append(queries, fmt.Sprintf(
+			"ALTER DATABASE %s SET %s",
+			postgres.SanitizeString(r.database.PostgresName()),
+			postgres.FormatSetting(name, desiredSettings[name]),
+		))
+	}
+
+	for _, name := range postgres.SortedSettingNames(currentSettings) {
+		if _, ok := desiredSettings[name]; ok {
+			continue
+		}
+		queries = append(queries, fmt.Sprintf(
+			"ALTER DATABASE %s RESET %s",
+			postgres.SanitizeString(r.database.PostgresName()),
+			postgres.SanitizeString(name),
+		))
+	}
+
+	for _, query := range queries {
+		log.FromContext(ctx).Info("computed database setting query", "query", query)
+
+		if _, err = r.conn.Exec(ctx, query); err != nil {
+			return r.newRepositoryError(err)
+		}
+	}
+
+	if len(invalidSettings) > 0 {
+		return fmt.Errorf(
+			"skipped invalid settings for database '%s': '%s'",
+			r.database.PostgresName(),
+			strings.Join(invalidSettings, "', '"),
+		)
+	}
+
+	return nil
+}
+
+// GetSettings returns the settings of the database, that apply to all roles.
+func (r *Repository) GetSettings(ctx context.Context) (map[string]string, error) {
+	var setconfig []string
+	err := r.conn.QueryRow(
+		ctx,
+		`SELECT coalesce(s.setconfig, '{}')
+		FROM pg_catalog.pg_database AS d
+		LEFT JOIN pg_catalog.pg_db_role_setting AS s ON s.setdatabase = d.oid AND s.setrole = 0
+		WHERE d.datname = $1`,
+		r.database.PostgresName(),
+	).Scan(&setconfig)
+
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	return postgres.ParseSettings(setconfig), nil
+}
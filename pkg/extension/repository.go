@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/georgysavva/scany/pgxscan"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/pkg/postgres"
 )
@@ -14,7 +14,7 @@ const latest = "latest"
 type Repository struct {
 	database   *v1alpha1.Database
 	connection *v1alpha1.Connection
-	conn       *pgx.Conn
+	conn       *pgxpool.Pool
 }
 
 type RepositoryError struct {
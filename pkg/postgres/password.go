@@ -0,0 +1,153 @@
+package postgres
+
+import (
+	"crypto/hmac"
+	"crypto/md5" //nolint:gosec // md5 is required to compute PostgreSQL md5 verifiers
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// ScramIterations is the iteration count, that PostgreSQL uses by default for SCRAM-SHA-256 verifiers.
+	ScramIterations = 4096
+	// ScramSaltLength is the salt length, that PostgreSQL uses for SCRAM-SHA-256 verifiers.
+	ScramSaltLength = 16
+
+	scramPrefix = "SCRAM-SHA-256$"
+	md5Prefix   = "md5"
+)
+
+var (
+	md5VerifierPattern   = regexp.MustCompile(`^md5[0-9a-f]{32}$`)
+	scramVerifierPattern = regexp.MustCompile(`^SCRAM-SHA-256\$([0-9]+):([A-Za-z0-9+/]+={0,2})\$([A-Za-z0-9+/]+={0,2}):([A-Za-z0-9+/]+={0,2})$`)
+)
+
+// IsPasswordVerifier checks whether the given password is already a SCRAM-SHA-256 or md5 verifier, that can
+// be passed to PostgreSQL as it is.
+func IsPasswordVerifier(password string) bool {
+	return scramVerifierPattern.MatchString(password) || md5VerifierPattern.MatchString(password)
+}
+
+// ScramSHA256 computes a SCRAM-SHA-256 verifier for the given password with a random salt, so that
+// the plaintext password never has to be sent to the PostgreSQL server.
+func ScramSHA256(password string) (string, error) {
+	salt := make([]byte, ScramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to generate salt: '%w'", err)
+	}
+
+	return scramSHA256(password, salt, ScramIterations), nil
+}
+
+// MD5 computes an md5 verifier for the given password and role name.
+func MD5(password string, role string) string {
+	checksum := md5.Sum([]byte(password + role)) //nolint:gosec // md5 is required by the verifier format
+	return md5Prefix + hex.EncodeToString(checksum[:])
+}
+
+// VerifyPassword checks whether the given verifier, as stored within pg_authid, matches the given password.
+// SCRAM-SHA-256 verifiers are recomputed with their salt and iteration count, md5 verifiers with the role name.
+// If the password already is a verifier, both verifiers are compared as they are.
+func VerifyPassword(password string, verifier string, role string) bool {
+	if IsPasswordVerifier(password) {
+		return password == verifier
+	}
+
+	if md5VerifierPattern.MatchString(verifier) {
+		return subtle.ConstantTimeCompare([]byte(MD5(password, role)), []byte(verifier)) == 1
+	}
+
+	matches := scramVerifierPattern.FindStringSubmatch(verifier)
+	if matches == nil {
+		return false
+	}
+
+	iterations, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return false
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(scramSHA256(password, salt, iterations)), []byte(verifier)) == 1
+}
+
+// scramSHA256 computes the verifier in the format PostgreSQL stores it within pg_authid:
+// SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+func scramSHA256(password string, salt []byte, iterations int) string {
+	saltedPassword := pbkdf2.Key([]byte(saslPrep(password)), salt, iterations, sha256.Size, sha256.New)
+
+	clientKey := computeHMAC(saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := computeHMAC(saltedPassword, "Server Key")
+
+	return fmt.Sprintf(
+		"%s%d:%s$%s:%s",
+		scramPrefix,
+		iterations,
+		base64.StdEncoding.EncodeToString(salt),
+		base64.StdEncoding.EncodeToString(storedKey[:]),
+		base64.StdEncoding.EncodeToString(serverKey),
+	)
+}
+
+func computeHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// saslPrep normalizes the password like PostgreSQL does before hashing it. Just like PostgreSQL, the
+// password is used as it is, if it can't be normalized.
+func saslPrep(password string) string {
+	isASCII := true
+	for _, char := range password {
+		if char > unicode.MaxASCII {
+			isASCII = false
+			break
+		}
+	}
+	if isASCII {
+		return password
+	}
+
+	var builder strings.Builder
+	for _, char := range password {
+		switch {
+		// map non-ASCII space characters to a space
+		case unicode.Is(unicode.Zs, char):
+			builder.WriteRune(' ')
+		// remove characters, that are commonly mapped to nothing
+		case char == '\u00AD' || char == '\u034F' || char == '\u1806' || char == '\u180B' ||
+			char == '\u180C' || char == '\u180D' || char == '\u200B' || char == '\u200C' ||
+			char == '\u200D' || char == '\u2060' || char == '\uFEFF' || (char >= '\uFE00' && char <= '\uFE0F'):
+			continue
+		default:
+			builder.WriteRune(char)
+		}
+	}
+
+	normalized := norm.NFKC.String(builder.String())
+	for _, char := range normalized {
+		// prohibited characters, fall back to the raw password
+		if unicode.IsControl(char) || unicode.Is(unicode.Co, char) || unicode.Is(unicode.Cs, char) {
+			return password
+		}
+	}
+
+	return normalized
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// pencilVerifier is the verifier for the password "pencil" with the salt and iteration count of the
+// SCRAM-SHA-256 example of RFC 7677, section 3. Its keys reproduce the client proof and server signature
+// of the example.
+const pencilVerifier = "SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==" +
+	"$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU="
+
+func TestScramSHA256KnownAnswer(t *testing.T) {
+	salt, err := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := scramSHA256("pencil", salt, 4096); got != pencilVerifier {
+		t.Errorf("scramSHA256() = %q, want %q", got, pencilVerifier)
+	}
+}
+
+func TestScramSHA256(t *testing.T) {
+	verifier, err := ScramSHA256("pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(verifier, "SCRAM-SHA-256$4096:") {
+		t.Errorf("ScramSHA256() = %q, want the default iteration count", verifier)
+	}
+	if !IsPasswordVerifier(verifier) {
+		t.Errorf("IsPasswordVerifier(%q) = false, want true", verifier)
+	}
+	if !VerifyPassword("pencil", verifier, "user") {
+		t.Errorf("VerifyPassword() = false, want true for the generated verifier")
+	}
+
+	other, err := ScramSHA256("pencil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == verifier {
+		t.Errorf("ScramSHA256() returned the same verifier twice, want a random salt")
+	}
+}
+
+func TestIsPasswordVerifier(t *testing.T) {
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: pencilVerifier, want: true},
+		{password: "md520c46e3762c864548e296b33c3406aa9", want: true},
+		{password: "pencil", want: false},
+		{password: "", want: false},
+		{password: "SCRAM-SHA-256$", want: false},
+		{password: "SCRAM-SHA-256$secret", want: false},
+		{password: "SCRAM-SHA-256$4096:salt", want: false},
+		{password: "SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$key", want: false},
+		{password: "SCRAM-SHA-256$abc:W22ZaJ0SNY7soEsUEjb6gQ==$a2V5:a2V5", want: false},
+		{password: "SCRAM-SHA-256$4096:W22Z$aJ0S$a2V5:a2V5", want: false},
+		{password: "md520C46E3762C864548E296B33C3406AA9", want: false},
+		{password: "md5pencil", want: false},
+	}
+
+	for _, test := range tests {
+		if got := IsPasswordVerifier(test.password); got != test.want {
+			t.Errorf("IsPasswordVerifier(%q) = %v, want %v", test.password, got, test.want)
+		}
+	}
+}
+
+func TestMD5(t *testing.T) {
+	// md5("pencil" || "user"), as computed by PostgreSQL for the role "user"
+	if got, want := MD5("pencil", "user"), "md520c46e3762c864548e296b33c3406aa9"; got != want {
+		t.Errorf("MD5() = %q, want %q", got, want)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		verifier string
+		role     string
+		want     bool
+	}{
+		{name: "scram match", password: "pencil", verifier: pencilVerifier, role: "user", want: true},
+		{name: "scram mismatch", password: "pen", verifier: pencilVerifier, role: "user", want: false},
+		{name: "scram ignores role", password: "pencil", verifier: pencilVerifier, role: "other", want: true},
+		{name: "md5 match", password: "pencil", verifier: "md520c46e3762c864548e296b33c3406aa9", role: "user", want: true},
+		{name: "md5 other role", password: "pencil", verifier: "md520c46e3762c864548e296b33c3406aa9", role: "other", want: false},
+		{name: "verifier as password", password: pencilVerifier, verifier: pencilVerifier, role: "user", want: true},
+		{name: "other verifier as password", password: "md520c46e3762c864548e296b33c3406aa9", verifier: pencilVerifier, role: "user", want: false},
+		{name: "malformed verifier", password: "pencil", verifier: "SCRAM-SHA-256$4096:salt", role: "user", want: false},
+		{name: "plaintext verifier", password: "pencil", verifier: "pencil", role: "user", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := VerifyPassword(test.password, test.verifier, test.role); got != test.want {
+				t.Errorf("VerifyPassword() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSaslPrep(t *testing.T) {
+	// examples of RFC 4013, section 3, plus the fallback of PostgreSQL for passwords, that can't be normalized
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{name: "soft hyphen mapped to nothing", password: "I\u00ADX", want: "IX"},
+		{name: "no transformation", password: "user", want: "user"},
+		{name: "case preserved", password: "USER", want: "USER"},
+		{name: "output is NFKC, input in ISO 8859-1", password: "\u00AA", want: "a"},
+		{name: "output is NFKC, will be in ISO 8859-1", password: "\u2168", want: "IX"},
+		{name: "non-ASCII space mapped to space", password: "a\u00A0b", want: "a b"},
+		{name: "ASCII control character used as it is", password: "\u0007", want: "\u0007"},
+		{name: "prohibited character used as it is", password: "\u00AA\u0007", want: "\u00AA\u0007"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := saslPrep(test.password); got != test.want {
+				t.Errorf("saslPrep(%q) = %q, want %q", test.password, got, test.want)
+			}
+		})
+	}
+
+	// passwords, that normalize to the same string, share their verifier
+	salt := []byte("0123456789abcdef")
+	if scramSHA256("I\u00ADX", salt, 4096) != scramSHA256("\u2168", salt, 4096) {
+		t.Errorf("scramSHA256() differs for passwords with the same normalized form")
+	}
+}
@@ -1,18 +1,58 @@
 package postgres
 
-import "github.com/jackc/pgx/v4"
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
 
 const (
-	TABLE    = "TABLE"
-	FUNCTION = "FUNCTION"
-	SCHEMA   = "SCHEMA"
-	COLUMN   = "COLUMN"
-	VIEW     = "VIEW"
-	SEQUENCE = "SEQUENCE"
+	TABLE     = "TABLE"
+	FUNCTION  = "FUNCTION"
+	PROCEDURE = "PROCEDURE"
+	ROUTINE   = "ROUTINE"
+	SCHEMA    = "SCHEMA"
+	COLUMN    = "COLUMN"
+	VIEW      = "VIEW"
+	SEQUENCE  = "SEQUENCE"
+	DATABASE  = "DATABASE"
+
+	MATERIALIZED_VIEW    = "MATERIALIZED_VIEW"
+	FOREIGN_TABLE        = "FOREIGN_TABLE"
+	TYPE                 = "TYPE"
+	DOMAIN               = "DOMAIN"
+	LANGUAGE             = "LANGUAGE"
+	FOREIGN_DATA_WRAPPER = "FOREIGN_DATA_WRAPPER"
+	FOREIGN_SERVER       = "FOREIGN_SERVER"
+	TABLESPACE           = "TABLESPACE"
+	LARGE_OBJECT         = "LARGE_OBJECT"
+	PARAMETER            = "PARAMETER"
 )
 
+// Querier is implemented by pgx connections, pools and transactions.
+type Querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// GetServerVersionNum returns the version of the PostgreSQL server as number, e.g. 150002 for 15.2.
+func GetServerVersionNum(ctx context.Context, querier Querier) (int, error) {
+	var version int
+	err := querier.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&version)
+	return version, err
+}
+
 func SanitizeString(input string) string {
 	var ids pgx.Identifier
 	ids = append(ids, input)
 	return ids.Sanitize()
 }
+
+// SanitizeLiteral quotes the given input, so that it can be used as string literal within a query.
+func SanitizeLiteral(input string) string {
+	literal := "'" + strings.ReplaceAll(input, "'", "''") + "'"
+	if strings.Contains(input, `\`) {
+		return "E" + strings.ReplaceAll(literal, `\`, `\\`)
+	}
+	return literal
+}
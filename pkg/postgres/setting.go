@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// listSettings are the settings, whose values are lists of quoted elements. Each element has to be passed as
+// separate literal, otherwise the whole list would be treated as a single element.
+var listSettings = map[string]bool{
+	"search_path":               true,
+	"temp_tablespaces":          true,
+	"session_preload_libraries": true,
+	"local_preload_libraries":   true,
+}
+
+// ParseSettings parses the settings, as stored within the setconfig column of pg_db_role_setting, e.g.
+// "work_mem=64MB". The names of the settings are returned in lowercase.
+func ParseSettings(setconfig []string) map[string]string {
+	settings := map[string]string{}
+	for _, setting := range setconfig {
+		name, value, found := strings.Cut(setting, "=")
+		if !found {
+			continue
+		}
+		settings[strings.ToLower(name)] = value
+	}
+	return settings
+}
+
+// EqualSettingValues compares two values of the given setting. Elements of list settings are compared without
+// their quotes and surrounding whitespaces.
+func EqualSettingValues(name string, a string, b string) bool {
+	if !listSettings[strings.ToLower(name)] {
+		return a == b
+	}
+
+	elementsA, elementsB := splitSettingList(a), splitSettingList(b)
+	if len(elementsA) != len(elementsB) {
+		return false
+	}
+	for i := range elementsA {
+		if elementsA[i] != elementsB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatSetting returns the clause, that sets the given setting within ALTER DATABASE or ALTER ROLE,
+// e.g. "work_mem = '64MB'".
+func FormatSetting(name string, value string) string {
+	if !listSettings[strings.ToLower(name)] {
+		return fmt.Sprintf("%s = %s", SanitizeString(strings.ToLower(name)), SanitizeLiteral(value))
+	}
+
+	var literals []string
+	for _, element := range splitSettingList(value) {
+		literals = append(literals, SanitizeLiteral(element))
+	}
+
+	return fmt.Sprintf("%s = %s", SanitizeString(strings.ToLower(name)), strings.Join(literals, ", "))
+}
+
+// GetInvalidSettings returns the given settings, that are unknown to the PostgreSQL server or can't be set per
+// database or role. Custom settings, that contain a dot, are always valid.
+func GetInvalidSettings(ctx context.Context, querier Querier, names []string) ([]string, error) {
+	var invalid []string
+	err := querier.QueryRow(
+		ctx,
+		`SELECT coalesce(array_agg(n.name ORDER BY n.name), '{}')
+		FROM unnest($1::text[]) AS n(name)
+		LEFT JOIN pg_catalog.pg_settings AS s ON s.name = lower(n.name)
+		WHERE (s.name IS NULL AND strpos(n.name, '.') = 0)
+		OR s.context NOT IN ('user', 'superuser')`,
+		names,
+	).Scan(&invalid)
+
+	return invalid, err
+}
+
+// SortedSettingNames returns the names of the given settings in a stable order.
+func SortedSettingNames(settings map[string]string) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func splitSettingList(value string) []string {
+	var elements []string
+	for _, element := range strings.Split(value, ",") {
+		element = strings.TrimSpace(element)
+		if len(element) >= 2 && strings.HasPrefix(element, `"`) && strings.HasSuffix(element, `"`) {
+			element = strings.ReplaceAll(element[1:len(element)-1], `""`, `"`)
+		}
+		if element != "" {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
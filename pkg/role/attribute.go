@@ -0,0 +1,202 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Attributes describe the attributes of a PostgreSQL role, as stored within pg_roles.
+type Attributes struct {
+	Superuser       bool
+	CreateDB        bool
+	CreateRole      bool
+	Inherit         bool
+	Login           bool
+	Replication     bool
+	BypassRLS       bool
+	ConnectionLimit int32
+	// ValidUntil is nil, if the password of the role is valid for all time.
+	ValidUntil *time.Time
+}
+
+// attributeKeywords maps the well-known role options to the attribute they control.
+var attributeKeywords = map[string]func(attributes *Attributes){
+	"SUPERUSER":     func(a *Attributes) { a.Superuser = true },
+	"NOSUPERUSER":   func(a *Attributes) { a.Superuser = false },
+	"CREATEDB":      func(a *Attributes) { a.CreateDB = true },
+	"NOCREATEDB":    func(a *Attributes) { a.CreateDB = false },
+	"CREATEROLE":    func(a *Attributes) { a.CreateRole = true },
+	"NOCREATEROLE":  func(a *Attributes) { a.CreateRole = false },
+	"INHERIT":       func(a *Attributes) { a.Inherit = true },
+	"NOINHERIT":     func(a *Attributes) { a.Inherit = false },
+	"LOGIN":         func(a *Attributes) { a.Login = true },
+	"NOLOGIN":       func(a *Attributes) { a.Login = false },
+	"REPLICATION":   func(a *Attributes) { a.Replication = true },
+	"NOREPLICATION": func(a *Attributes) { a.Replication = false },
+	"BYPASSRLS":     func(a *Attributes) { a.BypassRLS = true },
+	"NOBYPASSRLS":   func(a *Attributes) { a.BypassRLS = false },
+}
+
+func (r *Repository) ReconcileAttributes(ctx context.Context) error {
+	desiredAttributes, options := r.getDesiredAttributes()
+
+	currentAttributes, err := r.GetAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	clauses := getAttributeClauses(desiredAttributes, currentAttributes)
+
+	// options, that are not known to kubepost, are applied as they are
+	clauses = append(clauses, options...)
+
+	if len(clauses) == 0 {
+		log.FromContext(ctx).Info("role attributes are up to date, skipping alter role")
+		return nil
+	}
+
+	query := fmt.Sprintf(
+		"ALTER ROLE %s WITH %s",
+		postgres.SanitizeString(r.role.PostgresName()),
+		strings.Join(clauses, " "),
+	)
+
+	log.FromContext(ctx).Info("computed alter role query", "query", query)
+
+	_, err = r.conn.Exec(
+		ctx,
+		query,
+	)
+
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAttributes(ctx context.Context) (*Attributes, error) {
+	var attributes Attributes
+	var validUntil pgtype.Timestamptz
+
+	err := r.conn.QueryRow(
+		ctx,
+		`SELECT
+		rolsuper,
+		rolcreatedb,
+		rolcreaterole,
+		rolinherit,
+		rolcanlogin,
+		rolreplication,
+		rolbypassrls,
+		rolconnlimit,
+		rolvaliduntil
+		FROM pg_catalog.pg_roles
+		WHERE rolname = $1`,
+		r.role.PostgresName(),
+	).Scan(
+		&attributes.Superuser,
+		&attributes.CreateDB,
+		&attributes.CreateRole,
+		&attributes.Inherit,
+		&attributes.Login,
+		&attributes.Replication,
+		&attributes.BypassRLS,
+		&attributes.ConnectionLimit,
+		&validUntil,
+	)
+
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	// NULL and infinity both mean, that the password is valid for all time
+	if validUntil.Status == pgtype.Present && validUntil.InfinityModifier == pgtype.None {
+		attributes.ValidUntil = &validUntil.Time
+	}
+
+	return &attributes, nil
+}
+
+// getDesiredAttributes computes the desired attributes from the typed role attributes. Well-known options
+// of the deprecated options field take precedence, all other options are returned as they are.
+func (r *Repository) getDesiredAttributes() (*Attributes, []string) {
+	attributes := Attributes{
+		Superuser:       r.role.Spec.Superuser,
+		CreateDB:        r.role.Spec.CreateDB,
+		CreateRole:      r.role.Spec.CreateRole,
+		Inherit:         r.role.Spec.Inherit,
+		Login:           r.role.Spec.Login,
+		Replication:     r.role.Spec.Replication,
+		BypassRLS:       r.role.Spec.BypassRLS,
+		ConnectionLimit: r.role.Spec.ConnectionLimit,
+	}
+
+	if r.role.Spec.ValidUntil != nil {
+		validUntil := r.role.Spec.ValidUntil.Time
+		attributes.ValidUntil = &validUntil
+	}
+
+	// rotated passwords expire, once the expiry margin after the next rotation has passed
+	if validUntil := getRotationValidUntil(r.role); validUntil != nil {
+		attributes.ValidUntil = &validUntil.Time
+	}
+
+	var options []string
+	for _, option := range r.role.Spec.Options {
+		apply, ok := attributeKeywords[strings.ToUpper(strings.TrimSpace(option))]
+		if !ok {
+			options = append(options, option)
+			continue
+		}
+		apply(&attributes)
+	}
+
+	return &attributes, options
+}
+
+// getAttributeClauses returns the clauses, that are required to alter the current attributes to the desired ones.
+func getAttributeClauses(desired, current *Attributes) []string {
+	var clauses []string
+
+	flag := func(desired, current bool, keyword string) {
+		if desired == current {
+			return
+		}
+		if desired {
+			clauses = append(clauses, keyword)
+		} else {
+			clauses = append(clauses, "NO"+keyword)
+		}
+	}
+
+	flag(desired.Superuser, current.Superuser, "SUPERUSER")
+	flag(desired.CreateDB, current.CreateDB, "CREATEDB")
+	flag(desired.CreateRole, current.CreateRole, "CREATEROLE")
+	flag(desired.Inherit, current.Inherit, "INHERIT")
+	flag(desired.Login, current.Login, "LOGIN")
+	flag(desired.Replication, current.Replication, "REPLICATION")
+	flag(desired.BypassRLS, current.BypassRLS, "BYPASSRLS")
+
+	if desired.ConnectionLimit != current.ConnectionLimit {
+		clauses = append(clauses, fmt.Sprintf("CONNECTION LIMIT %d", desired.ConnectionLimit))
+	}
+
+	switch {
+	case desired.ValidUntil == nil && current.ValidUntil != nil:
+		clauses = append(clauses, "VALID UNTIL 'infinity'")
+	case desired.ValidUntil != nil && (current.ValidUntil == nil || !desired.ValidUntil.Equal(current.ValidUntil.Truncate(time.Second))):
+		clauses = append(clauses, fmt.Sprintf(
+			"VALID UNTIL %s",
+			postgres.SanitizeLiteral(desired.ValidUntil.UTC().Format(time.RFC3339)),
+		))
+	}
+
+	return clauses
+}
@@ -0,0 +1,253 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/postgres"
+)
+
+// defaultPrivilegeObjectTypes maps the object types, as stored within the defaclobjtype column of pg_default_acl,
+// to the object types of ALTER DEFAULT PRIVILEGES.
+var defaultPrivilegeObjectTypes = map[string]string{
+	"r": "TABLES",
+	"S": "SEQUENCES",
+	"f": "FUNCTIONS",
+	"T": "TYPES",
+	"n": "SCHEMAS",
+}
+
+// defaultPrivilegesAll holds the privileges, that "ALL" expands to for each object type.
+var defaultPrivilegesAll = map[string][]v1alpha1.Privilege{
+	"TABLES":    {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+	"SEQUENCES": {"USAGE", "SELECT", "UPDATE"},
+	"FUNCTIONS": {"EXECUTE"},
+	"TYPES":     {"USAGE"},
+	"SCHEMAS":   {"USAGE", "CREATE"},
+}
+
+// defaultPrivilegeTarget identifies a set of default privileges. An empty schema denotes default privileges,
+// that apply to all schemas.
+type defaultPrivilegeTarget struct {
+	Role            string
+	Schema          string
+	ObjectType      string
+	WithGrantOption bool
+}
+
+// getDefaultPrivilegeStatements returns the statements, that grant the desired default privileges of the role within
+// the database, the repository is currently connected to, and revoke all default privileges, that are not desired
+// anymore.
+func (r *Repository) getDefaultPrivilegeStatements(ctx context.Context, database string) ([]string, error) {
+	desiredPrivileges, err := r.getDefaultPrivilegeSet(ctx, database, r.role.Spec.DefaultPrivileges)
+	if err != nil {
+		return nil, err
+	}
+
+	// default privileges are recorded with their target role as grantor, therefore only the default privileges, that
+	// have been applied by kubepost before, are revoked, if the grants are managed additively
+	var appliedPrivileges map[defaultPrivilegeTarget]map[v1alpha1.Privilege]bool
+	if r.role.Spec.GrantManagement == GrantManagementAdditive {
+		appliedPrivileges, err = r.getDefaultPrivilegeSet(ctx, database, r.role.Status.AppliedDefaultPrivileges)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	currentPrivileges, err := r.GetDefaultPrivileges(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []defaultPrivilegeTarget
+	for target := range desiredPrivileges {
+		targets = append(targets, target)
+	}
+	for target := range currentPrivileges {
+		if _, ok := desiredPrivileges[target]; !ok {
+			targets = append(targets, target)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return fmt.Sprint(targets[i]) < fmt.Sprint(targets[j])
+	})
+
+	// revoke first, a privilege, that is granted with a different grant option, has to be revoked completely
+	// before it can be granted again
+	var revokeQueries, grantQueries []string
+	for _, target := range targets {
+		desired, current := desiredPrivileges[target], currentPrivileges[target]
+
+		revocable := current
+		if appliedPrivileges != nil {
+			revocable = intersectPrivileges(current, appliedPrivileges[target])
+		}
+
+		if privileges := subtractPrivileges(revocable, desired); len(privileges) > 0 {
+			revokeQueries = append(revokeQueries, r.createDefaultPrivilegeQuery(target, privileges, false))
+		}
+		if privileges := subtractPrivileges(desired, current); len(privileges) > 0 {
+			grantQueries = append(grantQueries, r.createDefaultPrivilegeQuery(target, privileges, true))
+		}
+	}
+
+	return append(revokeQueries, grantQueries...), nil
+}
+
+// GetDefaultPrivileges returns the default privileges, that are granted to the role within the database, the
+// repository is currently connected to. Default privileges, that a role holds on its own objects, are omitted.
+func (r *Repository) GetDefaultPrivileges(ctx context.Context) (map[defaultPrivilegeTarget]map[v1alpha1.Privilege]bool, error) {
+	rows, err := r.conn.Query(
+		ctx,
+		`SELECT pg_catalog.pg_get_userbyid(d.defaclrole), coalesce(n.nspname, ''), d.defaclobjtype::text,
+			a.privilege_type, a.is_grantable
+		FROM pg_catalog.pg_default_acl AS d
+		LEFT JOIN pg_catalog.pg_namespace AS n ON n.oid = d.defaclnamespace
+		CROSS JOIN LATERAL pg_catalog.aclexplode(d.defaclacl) AS a
+		JOIN pg_catalog.pg_roles AS r ON r.oid = a.grantee
+		WHERE r.rolname = $1 AND a.grantee <> d.defaclrole`,
+		r.role.PostgresName(),
+	)
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+	defer rows.Close()
+
+	privileges := map[defaultPrivilegeTarget]map[v1alpha1.Privilege]bool{}
+	for rows.Next() {
+		var target defaultPrivilegeTarget
+		var objectType, privilege string
+		if err = rows.Scan(&target.Role, &target.Schema, &objectType, &privilege, &target.WithGrantOption); err != nil {
+			return nil, r.newRepositoryError(err)
+		}
+
+		target.ObjectType = defaultPrivilegeObjectTypes[objectType]
+		if privileges[target] == nil {
+			privileges[target] = map[v1alpha1.Privilege]bool{}
+		}
+		privileges[target][v1alpha1.Privilege(privilege)] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	return privileges, nil
+}
+
+// getDefaultPrivilegeSet returns the given default privileges of the role within the given database by target.
+// Default privileges without a role apply to the objects created by the role kubepost connects with.
+func (r *Repository) getDefaultPrivilegeSet(ctx context.Context, database string, defaultPrivileges []v1alpha1.DefaultPrivilege) (map[defaultPrivilegeTarget]map[v1alpha1.Privilege]bool, error) {
+	privileges := map[defaultPrivilegeTarget]map[v1alpha1.Privilege]bool{}
+
+	var currentUser string
+	for _, defaultPrivilege := range defaultPrivileges {
+		if defaultPrivilege.Database != database {
+			continue
+		}
+
+		if defaultPrivilege.ObjectType == "SCHEMAS" && defaultPrivilege.Schema != "" {
+			return nil, fmt.Errorf(
+				"default privileges on schemas for role '%s' in database '%s' can't be restricted to schema '%s'",
+				r.role.PostgresName(),
+				database,
+				defaultPrivilege.Schema,
+			)
+		}
+
+		target := defaultPrivilegeTarget{
+			Role:            defaultPrivilege.Role,
+			Schema:          defaultPrivilege.Schema,
+			ObjectType:      defaultPrivilege.ObjectType,
+			WithGrantOption: defaultPrivilege.WithGrantOption,
+		}
+
+		if target.Role == "" {
+			if currentUser == "" {
+				err := r.conn.QueryRow(ctx, "SELECT current_user").Scan(&currentUser)
+				if err != nil {
+					return nil, r.newRepositoryError(err)
+				}
+			}
+			target.Role = currentUser
+		}
+
+		if privileges[target] == nil {
+			privileges[target] = map[v1alpha1.Privilege]bool{}
+		}
+		for _, privilege := range defaultPrivilege.Privileges {
+			if privilege == "ALL" {
+				for _, expanded := range defaultPrivilegesAll[target.ObjectType] {
+					privileges[target][expanded] = true
+				}
+				continue
+			}
+			privileges[target][privilege] = true
+		}
+	}
+
+	return privileges, nil
+}
+
+func (r *Repository) createDefaultPrivilegeQuery(target defaultPrivilegeTarget, privileges []v1alpha1.Privilege, grant bool) string {
+	query := fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR ROLE %s", postgres.SanitizeString(target.Role))
+	if target.Schema != "" {
+		query = fmt.Sprintf("%s IN SCHEMA %s", query, postgres.SanitizeString(target.Schema))
+	}
+
+	joined := make([]string, len(privileges))
+	for index, privilege := range privileges {
+		joined[index] = string(privilege)
+	}
+
+	if !grant {
+		return fmt.Sprintf(
+			"%s REVOKE %s ON %s FROM %s",
+			query,
+			strings.Join(joined, ", "),
+			target.ObjectType,
+			postgres.SanitizeString(r.role.PostgresName()),
+		)
+	}
+
+	query = fmt.Sprintf(
+		"%s GRANT %s ON %s TO %s",
+		query,
+		strings.Join(joined, ", "),
+		target.ObjectType,
+		postgres.SanitizeString(r.role.PostgresName()),
+	)
+	if target.WithGrantOption {
+		query += " WITH GRANT OPTION"
+	}
+
+	return query
+}
+
+// intersectPrivileges returns the privileges, that are contained within a and b.
+func intersectPrivileges(a, b map[v1alpha1.Privilege]bool) map[v1alpha1.Privilege]bool {
+	result := map[v1alpha1.Privilege]bool{}
+	for privilege := range a {
+		if b[privilege] {
+			result[privilege] = true
+		}
+	}
+	return result
+}
+
+// subtractPrivileges returns the privileges of a, that are not contained within b, in a stable order.
+func subtractPrivileges(a, b map[v1alpha1.Privilege]bool) []v1alpha1.Privilege {
+	var result []v1alpha1.Privilege
+	for privilege := range a {
+		if !b[privilege] {
+			result = append(result, privilege)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
@@ -7,51 +7,60 @@ import (
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
-	"github.com/orbatschow/kubepost/pkg/connection"
 	"github.com/orbatschow/kubepost/pkg/postgres"
+	"k8s.io/utils/strings/slices"
+	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-func (r *Repository) ReconcileGrants(ctx context.Context, ctrlClient client.Client) error {
+// ReconcileGrants grants and revokes the privileges of the role within all databases of the grants. If the grants
+// are managed additively, only privileges, that have been granted by kubepost according to the given applied grants,
+// are revoked. The applied grants are returned updated with the privileges, that have been granted or revoked. They
+// are returned on errors as well, so that privileges, that have been granted already, are still tracked.
+func (r *Repository) ReconcileGrants(ctx context.Context, ctrlClient client.Client, appliedGrants []v1alpha1.Grant) ([]v1alpha1.Grant, error) {
 	var err error
+	var failedStatements []v1alpha1.FailedStatement
 
-	defaultDatabase := r.connection.Spec.Database
+	defaultConn := r.conn
 
-	databases, err := r.GetDatabaseNames(ctx)
+	additive := r.role.Spec.GrantManagement == GrantManagementAdditive
+	if !additive {
+		appliedGrants = nil
+	}
+
+	databases, err := r.GetGrantDatabaseNames(ctx)
 	if err != nil {
-		return err
+		return appliedGrants, err
 	}
 
 	log.FromContext(ctx).Info("computed databases for grant", "databases", databases)
 
+	// reset the connection to the database, that was configured within the CRD
+	defer func() {
+		r.conn = defaultConn
+	}()
+
 	for _, database := range databases {
-		// we have to connect to all databases to grant/revoke the privileges
-		// therefore we will modify the connection for each database
-		r.connection.Spec.Database = database
-		r.conn, err = connection.GetConnection(ctx, ctrlClient, r.connection)
+		// we have to connect to each database to grant/revoke the privileges
+		// therefore we will switch the pool for each database
+		r.conn, err = r.pools.GetPool(ctx, ctrlClient, r.connection, database)
 		if err != nil {
-			return err
-		}
-
-		var grantObjects []v1alpha1.GrantObject
-
-		for _, grant := range r.role.Spec.Grants {
-			if grant.Database == database {
-				grantObjects = append(grantObjects, grant.Objects...)
-			}
+			return appliedGrants, err
 		}
 
 		// regex
-		grantObjects, err = r.regexExpandGrantObjects(ctx, grantObjects)
+		grantObjects, err := r.regexExpandGrantObjects(ctx, r.getGrantObjects(r.role.Spec.Grants, database))
 		if err != nil {
-			return err
+			return appliedGrants, err
 		}
 
-		currentGrants, err := r.GetCurrentGrants(ctx)
+		currentGrants, err := r.GetCurrentGrants(ctx, database)
 		if err != nil {
-			return err
+			return appliedGrants, err
 		}
 
 		// get desired and undesired grants by subtracting the intersections of
@@ -61,96 +70,296 @@ func (r *Repository) ReconcileGrants(ctx context.Context, ctrlClient client.Clie
 			currentGrants,
 		)
 
-		err = r.Grant(ctx, desiredGrants)
+		// only revoke privileges, that have been granted by kubepost, if the grants are managed additively. The
+		// grantor recorded by PostgreSQL can't be used instead, as it is the owner of the object for superusers.
+		applied := getAppliedGrantObjects(appliedGrants, database)
+		if additive {
+			undesiredGrants = filterAppliedGrants(undesiredGrants, applied)
+		}
+
+		defaultPrivilegeStatements, err := r.getDefaultPrivilegeStatements(ctx, database)
 		if err != nil {
-			return err
+			return appliedGrants, err
 		}
 
-		err = r.Revoke(ctx, undesiredGrants)
+		// revoke first, a privilege, that is granted with a different grant option, has to be revoked completely
+		// before it can be granted again
+		var statements []string
+		statements = append(statements, r.createRevokeQueries(ctx, undesiredGrants)...)
+		statements = append(statements, r.createGrantQueries(ctx, desiredGrants)...)
+		statements = append(statements, defaultPrivilegeStatements...)
+
+		// failures of single statements don't prevent the other databases from being reconciled
+		failed, err := r.applyGrantStatements(ctx, database, statements)
 		if err != nil {
-			return err
+			return appliedGrants, err
 		}
+		failedStatements = append(failedStatements, failed...)
+
+		if !additive {
+			continue
+		}
+
+		// the privileges are read again, so that only the statements, that succeeded, are tracked
+		if len(statements) > 0 {
+			currentGrants, err = r.GetCurrentGrants(ctx, database)
+			if err != nil {
+				return appliedGrants, err
+			}
+		}
+		appliedGrants = setAppliedGrantObjects(
+			appliedGrants,
+			database,
+			getAppliedGrantKeys(applied, desiredGrants, currentGrants),
+		)
 	}
 
-	// reset the database to the previous database, that was configured within the CRD
-	r.connection.Spec.Database = defaultDatabase
-	r.conn, err = connection.GetConnection(ctx, ctrlClient, r.connection)
-	if err != nil {
-		return err
+	// databases without any privileges of the role don't hold applied grants anymore, e.g. if they have been dropped
+	var result []v1alpha1.Grant
+	for _, grant := range appliedGrants {
+		if slices.Contains(databases, grant.Database) {
+			result = append(result, grant)
+		}
 	}
+	appliedGrants = result
 
-	return nil
+	if len(failedStatements) > 0 {
+		return appliedGrants, &GrantError{
+			Role:             r.role.PostgresName(),
+			FailedStatements: failedStatements,
+		}
+	}
+
+	return appliedGrants, nil
 }
 
-func (r *Repository) GetCurrentGrants(ctx context.Context) ([]v1alpha1.GrantObject, error) {
-	var currentGrants []v1alpha1.GrantObject
-	var buffer []v1alpha1.GrantObject
+// getGrantObjects returns the objects of the given grants, that have to be reconciled within the given database.
+func (r *Repository) getGrantObjects(grants []v1alpha1.Grant, database string) []v1alpha1.GrantObject {
+	var grantObjects []v1alpha1.GrantObject
 
-	buffer, err := r.getGrantsByType(ctx, postgres.TABLE)
-	currentGrants = append(currentGrants, buffer...)
-	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
+	for _, grant := range grants {
+		for _, grantObject := range grant.Objects {
+			// shared objects exist once per connection, they are reconciled within the database of the connection
+			if sharedGrantTypes[grantObject.Type] {
+				if database == r.connection.Spec.Database {
+					grantObjects = append(grantObjects, grantObject)
+				}
+				continue
+			}
+
+			if grant.Database == database {
+				grantObjects = append(grantObjects, grantObject)
+			}
 		}
 	}
 
-	buffer, err = r.getGrantsByType(ctx, postgres.SCHEMA)
-	currentGrants = append(currentGrants, buffer...)
+	return grantObjects
+}
+
+// grantTypes holds all grant types, whose current grants are read from PostgreSQL. The grants on domains are read
+// together with the grants on types.
+var grantTypes = []string{
+	postgres.TABLE,
+	postgres.SCHEMA,
+	postgres.COLUMN,
+	postgres.FUNCTION,
+	postgres.SEQUENCE,
+	postgres.DATABASE,
+	postgres.TYPE,
+	postgres.LANGUAGE,
+	postgres.FOREIGN_DATA_WRAPPER,
+	postgres.FOREIGN_SERVER,
+	postgres.TABLESPACE,
+	postgres.LARGE_OBJECT,
+	postgres.PARAMETER,
+}
+
+// sharedGrantTypes holds the grant types, whose objects are shared by all databases of a connection.
+var sharedGrantTypes = map[string]bool{
+	postgres.TABLESPACE: true,
+	postgres.PARAMETER:  true,
+}
+
+// parameterGrantsMinVersion is the first PostgreSQL version, that supports grants on parameters.
+const parameterGrantsMinVersion = 150000
+
+// grantKey identifies a single privilege on a PostgreSQL object.
+type grantKey struct {
+	Type            string
+	Schema          string
+	Table           string
+	Identifier      string
+	Signature       string
+	Privilege       v1alpha1.Privilege
+	WithGrantOption bool
+}
 
+// GetCurrentGrants returns the privileges, that are granted to the role within the database, the repository is
+// currently connected to. All privileges are read with a single query from the ACLs of the system catalogs, each
+// returned grant object holds a single privilege. Privileges on shared objects are only returned within the
+// database of the connection.
+func (r *Repository) GetCurrentGrants(ctx context.Context, database string) ([]v1alpha1.GrantObject, error) {
+	version, err := postgres.GetServerVersionNum(ctx, r.conn)
 	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
-		}
+		return nil, r.newRepositoryError(err)
 	}
 
-	buffer, err = r.getGrantsByType(ctx, postgres.COLUMN)
-	currentGrants = append(currentGrants, buffer...)
+	var queries []string
+	for _, grantType := range grantTypes {
+		if sharedGrantTypes[grantType] && database != r.connection.Spec.Database {
+			continue
+		}
+		if grantType == postgres.PARAMETER && version < parameterGrantsMinVersion {
+			continue
+		}
+		queries = append(queries, grantQueries[grantType])
+	}
 
+	rows, err := r.conn.Query(
+		ctx,
+		strings.Join(queries, "\n\t\tunion all"),
+		r.role.PostgresName(),
+	)
 	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
+		return nil, r.newRepositoryError(err)
+	}
+	defer rows.Close()
+
+	var currentGrants []v1alpha1.GrantObject
+	for rows.Next() {
+		var grant v1alpha1.GrantObject
+		var privilege string
+		err = rows.Scan(
+			&grant.Type,
+			&grant.Schema,
+			&grant.Table,
+			&grant.Identifier,
+			&grant.Signature,
+			&privilege,
+			&grant.WithGrantOption,
+		)
+		if err != nil {
+			return nil, r.newRepositoryError(err)
 		}
+
+		// privileges, that consist of multiple words, are named with underscores, e.g. ALTER_SYSTEM
+		grant.Privileges = []v1alpha1.Privilege{v1alpha1.Privilege(strings.ReplaceAll(privilege, " ", "_"))}
+		currentGrants = append(currentGrants, grant)
 	}
 
-	buffer, err = r.getGrantsByType(ctx, postgres.FUNCTION)
-	currentGrants = append(currentGrants, buffer...)
+	if err = rows.Err(); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
 
-	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
-		}
+	return currentGrants, nil
+}
+
+const (
+	GrantApplyModeTransaction = "Transaction"
+	GrantApplyModeStatement   = "Statement"
+
+	GrantManagementAuthoritative = "Authoritative"
+	GrantManagementAdditive      = "Additive"
+
+	MatchTypeExact = "Exact"
+	MatchTypeGlob  = "Glob"
+	MatchTypeRegex = "Regex"
+)
+
+// GrantError is returned, if grant statements could not be applied. It holds all failed statements, so that they
+// can be reported within the status of the role.
+type GrantError struct {
+	Role             string
+	FailedStatements []v1alpha1.FailedStatement
+}
+
+func (e *GrantError) Error() string {
+	first := e.FailedStatements[0]
+	return fmt.Sprintf(
+		"%d grant statement(s) for role '%s' failed, first failure in database '%s': '%s'",
+		len(e.FailedStatements),
+		e.Role,
+		first.Database,
+		first.Message,
+	)
+}
+
+// applyGrantStatements applies the given statements within the database, the repository is currently connected
+// to. By default, all statements are sent as a single batch within a transaction, which is rolled back on the first
+// failure. If the role requests it, each statement is applied on its own and all failures are collected instead.
+// Failed statements are returned, the returned error is only set, if the statements could not be applied at all.
+func (r *Repository) applyGrantStatements(ctx context.Context, database string, statements []string) ([]v1alpha1.FailedStatement, error) {
+	if len(statements) == 0 {
+		return nil, nil
 	}
 
-	buffer, err = r.getGrantsByType(ctx, postgres.SEQUENCE)
-	currentGrants = append(currentGrants, buffer...)
+	for _, statement := range statements {
+		log.FromContext(ctx).Info("computed grant statement", "statement", statement, "database", database)
+	}
+
+	if r.role.Spec.GrantApplyMode == GrantApplyModeStatement {
+		var failed []v1alpha1.FailedStatement
+		for _, statement := range statements {
+			if _, err := r.conn.Exec(ctx, statement); err != nil {
+				failed = append(failed, getFailedStatement(database, statement, err))
+			}
+		}
+		return failed, nil
+	}
 
+	tx, err := r.conn.Begin(ctx)
 	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
+		return nil, r.newRepositoryError(err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	batch := &pgx.Batch{}
+	for _, statement := range statements {
+		batch.Queue(statement)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for _, statement := range statements {
+		if _, err = results.Exec(); err != nil {
+			_ = results.Close()
+			// the transaction is aborted, the remaining statements are not applied
+			return []v1alpha1.FailedStatement{getFailedStatement(database, statement, err)}, nil
 		}
 	}
 
-	return currentGrants, nil
+	if err = results.Close(); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	return nil, nil
 }
 
-func (r *Repository) Grant(ctx context.Context, desiredGrants []v1alpha1.GrantObject) error {
+func getFailedStatement(database string, statement string, err error) v1alpha1.FailedStatement {
+	failed := v1alpha1.FailedStatement{
+		Database:  database,
+		Statement: statement,
+		Message:   err.Error(),
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		failed.SQLState = pgErr.Code
+		failed.Message = pgErr.Message
+	}
+
+	return failed
+}
+
+func (r *Repository) createGrantQueries(ctx context.Context, desiredGrants []v1alpha1.GrantObject) []string {
 	log.FromContext(ctx).Info("reconciling desired grants", "grants", desiredGrants)
 
+	var queries []string
 	for _, desiredGrant := range desiredGrants {
 		query, err := r.createGrantQuery(
 			ctx,
@@ -162,37 +371,16 @@ func (r *Repository) Grant(ctx context.Context, desiredGrants []v1alpha1.GrantOb
 			continue
 		}
 
-		_, err = r.conn.Exec(
-			ctx,
-			query,
-		)
-
-		if err != nil {
-			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) {
-				return RepositoryError{
-					Role:                 r.role.ObjectMeta.Name,
-					Connection:           r.connection.ObjectMeta.Name,
-					Namespace:            r.role.ObjectMeta.Namespace,
-					Message:              "unable to apply revoke query",
-					PostgresErrorCode:    pgErr.Code,
-					PostgresErrorMessage: pgErr.Message,
-				}
-			}
-			return RepositoryError{
-				Role:       r.role.ObjectMeta.Name,
-				Connection: r.connection.ObjectMeta.Name,
-				Namespace:  r.role.ObjectMeta.Namespace,
-				Message:    fmt.Sprintf("unable to apply Grant query: '%s'", err.Error()),
-			}
-		}
+		queries = append(queries, query)
 	}
-	return nil
+
+	return queries
 }
 
-func (r *Repository) Revoke(ctx context.Context, undesiredGrants []v1alpha1.GrantObject) error {
+func (r *Repository) createRevokeQueries(ctx context.Context, undesiredGrants []v1alpha1.GrantObject) []string {
 	log.FromContext(ctx).Info("reconciling undesired grants", "grants", undesiredGrants)
 
+	var queries []string
 	for _, undesiredGrant := range undesiredGrants {
 		query, err := r.createRevokeQuery(
 			ctx,
@@ -204,45 +392,36 @@ func (r *Repository) Revoke(ctx context.Context, undesiredGrants []v1alpha1.Gran
 			continue // continue with the next grant statement
 		}
 
-		_, err = r.conn.Exec(
-			ctx,
-			query,
-		)
-
-		if err != nil {
-			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) {
-				return RepositoryError{
-					Role:                 r.role.ObjectMeta.Name,
-					Connection:           r.connection.ObjectMeta.Name,
-					Namespace:            r.role.ObjectMeta.Namespace,
-					Message:              "unable to apply Revoke query",
-					PostgresErrorCode:    pgErr.Code,
-					PostgresErrorMessage: pgErr.Message,
-				}
-			}
-			return RepositoryError{
-				Role:       r.role.ObjectMeta.Name,
-				Connection: r.connection.ObjectMeta.Name,
-				Namespace:  r.role.ObjectMeta.Namespace,
-				Message:    fmt.Sprintf("unable to apply Revoke query: '%s'", err.Error()),
-			}
-		}
+		queries = append(queries, query)
 	}
 
-	return nil
+	return queries
 }
 
 func (r *Repository) regexExpandGrantObjects(ctx context.Context, grantObjects []v1alpha1.GrantObject) ([]v1alpha1.GrantObject, error) {
 
 	var grantObjectsExpanded []v1alpha1.GrantObject
 	privileges := map[string][]v1alpha1.Privilege{
-		postgres.SCHEMA:   {"USAGE", "CREATE"},
-		postgres.TABLE:    {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
-		postgres.VIEW:     {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
-		postgres.COLUMN:   {"SELECT", "UPDATE", "INSERT", "REFERENCES"},
-		postgres.FUNCTION: {"EXECUTE"},
-		postgres.SEQUENCE: {"USAGE", "SELECT", "UPDATE"},
+		postgres.SCHEMA:            {"USAGE", "CREATE"},
+		postgres.TABLE:             {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+		postgres.VIEW:              {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+		postgres.MATERIALIZED_VIEW: {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+		postgres.FOREIGN_TABLE:     {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+		postgres.COLUMN:            {"SELECT", "UPDATE", "INSERT", "REFERENCES"},
+		postgres.FUNCTION:          {"EXECUTE"},
+		postgres.PROCEDURE:         {"EXECUTE"},
+		postgres.ROUTINE:           {"EXECUTE"},
+		postgres.SEQUENCE:          {"USAGE", "SELECT", "UPDATE"},
+		postgres.DATABASE:          {"CREATE", "CONNECT", "TEMPORARY"},
+
+		postgres.TYPE:                 {"USAGE"},
+		postgres.DOMAIN:               {"USAGE"},
+		postgres.LANGUAGE:             {"USAGE"},
+		postgres.FOREIGN_DATA_WRAPPER: {"USAGE"},
+		postgres.FOREIGN_SERVER:       {"USAGE"},
+		postgres.TABLESPACE:           {"CREATE"},
+		postgres.LARGE_OBJECT:         {"SELECT", "UPDATE"},
+		postgres.PARAMETER:            {"SET", "ALTER_SYSTEM"},
 	}
 
 	// In case "ALL" is chosen, replace it with an expanded version
@@ -254,74 +433,166 @@ func (r *Repository) regexExpandGrantObjects(ctx context.Context, grantObjects [
 		}
 	}
 
+	// PostgreSQL reports TEMP as TEMPORARY, normalize it to be able to compare it with the current grants
+	for index, grant := range grantObjects {
+		normalized := make([]v1alpha1.Privilege, len(grant.Privileges))
+		for privilegeIndex, privilege := range grant.Privileges {
+			if privilege == "TEMP" {
+				privilege = "TEMPORARY"
+			}
+			normalized[privilegeIndex] = privilege
+		}
+		grantObjects[index].Privileges = normalized
+	}
+
 	for _, grantObject := range grantObjects {
 
 		var err error
 		var rows pgx.Rows
+		var expanded []v1alpha1.GrantObject
 
 		switch grantObject.Type {
 		case postgres.SCHEMA:
+			grantObject.Schema = ""
+			grantObject.Table = ""
 			rows, err = r.conn.Query(
 				ctx,
-				`select nspname from pg_namespace where nspname ~ $1`,
-				"^"+grantObject.Identifier+"$",
-			)
-		case postgres.VIEW:
-			rows, err = r.conn.Query(
-				ctx,
-				`select
-					viewname
-				from pg_views
-				where schemaname ~ $1
-				and viewname ~ $2`,
-				"^"+grantObject.Schema+"$",
-				"^"+grantObject.Identifier+"$",
-			)
-		case postgres.TABLE:
-			rows, err = r.conn.Query(
-				ctx,
-				`select
-					tablename
-				from pg_tables
-				where schemaname ~ $1
-				and tablename ~ $2`,
-				"^"+grantObject.Schema+"$",
-				"^"+grantObject.Identifier+"$",
+				`select '', '', nspname from pg_namespace where nspname ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
 			)
+		case postgres.TABLE, postgres.VIEW, postgres.MATERIALIZED_VIEW, postgres.FOREIGN_TABLE:
+			grantObject.Table = ""
+			expanded, err = r.expandRelations(ctx, grantObject)
 		case postgres.COLUMN:
 			rows, err = r.conn.Query(
 				ctx,
 				`select
+					table_schema,
+					table_name,
 					column_name
 				from information_schema.columns
 				where table_schema ~ $1
 				and table_name ~ $2
 				and column_name ~ $3`,
-				"^"+grantObject.Schema+"$",
-				"^"+grantObject.Table+"$",
-				"^"+grantObject.Identifier+"$",
-			)
-		case postgres.FUNCTION:
-			rows, err = r.conn.Query(
-				ctx,
-				`select
-					routine_name
-				from information_schema.routines
-				where routine_schema ~ $1
-				and routine_name ~ $2`,
-				"^"+grantObject.Schema+"$",
-				"^"+grantObject.Identifier+"$",
+				getMatchPattern(grantObject.MatchType, grantObject.Schema),
+				getMatchPattern(grantObject.MatchType, grantObject.Table),
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
 			)
+		case postgres.FUNCTION, postgres.PROCEDURE, postgres.ROUTINE:
+			grantObject.Table = ""
+			expanded, err = r.expandRoutines(ctx, grantObject)
 		case postgres.SEQUENCE:
 			rows, err = r.conn.Query(
 				ctx,
 				`select
+					sequence_schema,
+					'',
 					sequence_name
 				from information_schema.sequences
 				where sequence_schema ~ $1
 				and sequence_name ~ $2`,
-				"^"+grantObject.Schema+"$",
-				"^"+grantObject.Identifier+"$",
+				getMatchPattern(grantObject.MatchType, grantObject.Schema),
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.DATABASE:
+			// database grants always apply to the database of the grant, that we are connected to
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', current_database()`,
+			)
+		case postgres.TYPE, postgres.DOMAIN:
+			// array types and the row types of relations follow the privileges of their origin
+			rows, err = r.conn.Query(
+				ctx,
+				`select
+					n.nspname,
+					'',
+					t.typname
+				from pg_catalog.pg_type t
+				join pg_catalog.pg_namespace n on (n.oid = t.typnamespace)
+				where n.nspname ~ $1
+				and t.typname ~ $2
+				and (t.typtype = 'd') = $3
+				and (t.typrelid = 0 or exists (
+					select 1 from pg_catalog.pg_class c where c.oid = t.typrelid and c.relkind = 'c'
+				))
+				and not exists (select 1 from pg_catalog.pg_type e where e.typarray = t.oid)`,
+				getMatchPattern(grantObject.MatchType, grantObject.Schema),
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+				grantObject.Type == postgres.DOMAIN,
+			)
+		case postgres.LANGUAGE:
+			// privileges can only be granted on trusted languages
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', lanname from pg_catalog.pg_language where lanname ~ $1 and lanpltrusted`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.FOREIGN_DATA_WRAPPER:
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', fdwname from pg_catalog.pg_foreign_data_wrapper where fdwname ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.FOREIGN_SERVER:
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', srvname from pg_catalog.pg_foreign_server where srvname ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.TABLESPACE:
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', spcname from pg_catalog.pg_tablespace where spcname ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.LARGE_OBJECT:
+			grantObject.Schema = ""
+			grantObject.Table = ""
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', oid::text from pg_catalog.pg_largeobject_metadata where oid::text ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+			)
+		case postgres.PARAMETER:
+			var version int
+			version, err = postgres.GetServerVersionNum(ctx, r.conn)
+			if err != nil {
+				return nil, err
+			}
+			if version < parameterGrantsMinVersion {
+				return nil, fmt.Errorf(
+					"grants on parameters require PostgreSQL 15 or later, parameter '%s' can't be granted",
+					grantObject.Identifier,
+				)
+			}
+
+			grantObject.Schema = ""
+			grantObject.Table = ""
+
+			// parameters of extensions, that have not been loaded yet, are missing within pg_settings, but can be
+			// granted by their exact name already, which PostgreSQL stores in lower case
+			if grantObject.MatchType == MatchTypeExact {
+				parameter := grantObject
+				parameter.Identifier = strings.ToLower(grantObject.Identifier)
+				expanded = append(expanded, parameter)
+				break
+			}
+
+			rows, err = r.conn.Query(
+				ctx,
+				`select '', '', name from pg_catalog.pg_settings where name ~ $1`,
+				getMatchPattern(grantObject.MatchType, grantObject.Identifier),
 			)
 		}
 
@@ -329,306 +600,371 @@ func (r *Repository) regexExpandGrantObjects(ctx context.Context, grantObjects [
 			return nil, err
 		}
 
-		var entries []string
-		for rows.Next() {
-			var entry string
-			err = rows.Scan(&entry)
+		// relations and routines are expanded by their own queries, all other types return their names
+		for rows != nil && rows.Next() {
+			entry := grantObject
+			err = rows.Scan(&entry.Schema, &entry.Table, &entry.Identifier)
 
 			if err != nil {
+				rows.Close()
 				return nil, err
 			}
 
-			entries = append(entries, entry)
+			expanded = append(expanded, entry)
 		}
 
-		for _, entry := range entries {
-			grantObject.Identifier = entry
-
-			if grantObject.Type == postgres.VIEW {
-				grantObject.Type = postgres.TABLE
-			}
-
-			grantObjectsExpanded = append(grantObjectsExpanded, grantObject)
+		expanded, err = r.excludeGrantObjects(ctx, grantObject, expanded)
+		if err != nil {
+			return nil, err
 		}
+
+		grantObjectsExpanded = append(grantObjectsExpanded, expanded...)
 	}
 
 	return grantObjectsExpanded, nil
 }
 
-func (r *Repository) getGrantsByType(ctx context.Context, grantType string) ([]v1alpha1.GrantObject, error) {
+// getGrantSymmetricDifference returns the grants, that are missing, and the grants, that are not desired anymore.
+// The grants are compared by single privileges, privileges on the same object are grouped again afterwards.
+func getGrantSymmetricDifference(desired, current []v1alpha1.GrantObject) ([]v1alpha1.GrantObject, []v1alpha1.GrantObject) {
+	desiredKeys, currentKeys := getGrantKeys(desired), getGrantKeys(current)
 
-	computePrivileges := func(privileges []string) []v1alpha1.Privilege {
-		var buffer []v1alpha1.Privilege
-		for _, privilege := range privileges {
-			buffer = append(buffer, v1alpha1.Privilege(privilege))
+	var missing, undesired []grantKey
+	for key := range desiredKeys {
+		if currentKeys[key] {
+			continue
 		}
-		return buffer
-	}
 
-	grantQueries := getGrantQueries()
-	var grants []v1alpha1.GrantObject
-
-	rows, err := r.conn.Query(
-		ctx,
-		grantQueries[grantType],
-		r.role.ObjectMeta.Name,
-	)
-
-	if err != nil {
-		return nil, RepositoryError{
-			Role:       r.role.ObjectMeta.Name,
-			Connection: r.connection.ObjectMeta.Name,
-			Namespace:  r.role.ObjectMeta.Namespace,
-			Message:    err.Error(),
+		// privileges on columns are already included, if the same privilege is desired on the whole table
+		if key.Type == postgres.COLUMN && desiredKeys[grantKey{
+			Type:            postgres.TABLE,
+			Schema:          key.Schema,
+			Identifier:      key.Table,
+			Privilege:       key.Privilege,
+			WithGrantOption: key.WithGrantOption,
+		}] {
+			continue
 		}
+
+		missing = append(missing, key)
 	}
 
-	for rows.Next() {
-		var privileges []string
-		var grant v1alpha1.GrantObject
-		err = rows.Scan(
-			&grant.Type,
-			&grant.Schema,
-			&grant.Table,
-			&grant.Identifier,
-			&privileges,
-			&grant.WithGrantOption,
-		)
-		if err != nil {
-			return nil, RepositoryError{
-				Role:       r.role.ObjectMeta.Name,
-				Connection: r.connection.ObjectMeta.Name,
-				Namespace:  r.role.ObjectMeta.Namespace,
-				Message:    err.Error(),
-			}
+	for key := range currentKeys {
+		if !desiredKeys[key] {
+			undesired = append(undesired, key)
 		}
-
-		grant.Privileges = computePrivileges(privileges)
-		grants = append(grants, grant)
 	}
 
-	return grants, nil
+	return groupGrantKeys(missing), groupGrantKeys(undesired)
 }
 
-func getGrantSymmetricDifference(a, b []v1alpha1.GrantObject) ([]v1alpha1.GrantObject, []v1alpha1.GrantObject) {
-
-	for outerIndex := 0; outerIndex < len(b); outerIndex++ {
-		currentGrant := &b[outerIndex]
-
-		for innerIndex := 0; innerIndex < len(a); innerIndex++ {
-			desiredGrant := &a[innerIndex]
-
-			if grantObjectGotSameTarget(desiredGrant, currentGrant) {
-				var aBuffer []v1alpha1.Privilege
-				var bBuffer []v1alpha1.Privilege
-
-				aBuffer = subtractPrivilegeConjunction(
-					desiredGrant.Privileges,
-					currentGrant.Privileges,
-				)
+// filterAppliedGrants returns the privileges of the given grants, that are contained within the applied grants.
+func filterAppliedGrants(grantObjects []v1alpha1.GrantObject, appliedGrants []v1alpha1.GrantObject) []v1alpha1.GrantObject {
+	applied := getGrantKeys(appliedGrants)
 
-				bBuffer = subtractPrivilegeConjunction(
-					currentGrant.Privileges,
-					desiredGrant.Privileges,
-				)
-
-				desiredGrant.Privileges = aBuffer
-				currentGrant.Privileges = bBuffer
-			}
-
-			if grantObjectIncludesTarget(desiredGrant, currentGrant) {
-				desiredGrant.Privileges = subtractPrivilegeConjunction(
-					desiredGrant.Privileges,
-					currentGrant.Privileges,
-				)
-			}
+	var keys []grantKey
+	for key := range getGrantKeys(grantObjects) {
+		if applied[key] {
+			keys = append(keys, key)
+		}
+	}
+	return groupGrantKeys(keys)
+}
 
-			// In case there are no privileges left in currentGrant: remove it
-			if len(desiredGrant.Privileges) == 0 {
-				a[innerIndex] = a[len(a)-1] // Copy last element to index
-				a = a[:len(a)-1]            // Truncate slice.
-				innerIndex--
-			}
+// getAppliedGrantKeys returns the privileges, that have been granted by kubepost. These are the privileges, that
+// have been applied before or have just been granted, as long as they are still granted to the role. Privileges,
+// that have been granted by others, are never included, even if they are part of the spec.
+func getAppliedGrantKeys(applied, granted, current []v1alpha1.GrantObject) []grantKey {
+	appliedKeys, grantedKeys := getGrantKeys(applied), getGrantKeys(granted)
 
-			if len(currentGrant.Privileges) == 0 {
-				b[outerIndex] = b[len(b)-1] // Copy last element to index
-				b = b[:len(b)-1]            // Truncate slice.
-				outerIndex--
-				break
-			}
+	var keys []grantKey
+	for key := range getGrantKeys(current) {
+		if appliedKeys[key] || grantedKeys[key] {
+			keys = append(keys, key)
 		}
 	}
-
-	return a, b
+	return keys
 }
 
-func grantObjectGotSameTarget(a, b *v1alpha1.GrantObject) bool {
-	if a.Type != b.Type {
-		return false
-	}
-	if a.Schema != b.Schema {
-		return false
+// getAppliedGrantObjects returns the objects of the applied grants within the given database.
+func getAppliedGrantObjects(appliedGrants []v1alpha1.Grant, database string) []v1alpha1.GrantObject {
+	for _, grant := range appliedGrants {
+		if grant.Database == database {
+			return grant.Objects
+		}
 	}
-	if a.Type == "COLUMN" && a.Table != b.Table {
-		return false
+	return nil
+}
+
+// setAppliedGrantObjects replaces the applied grants within the given database with the given privileges. The
+// objects are matched exactly, as they hold the names of the objects the privileges have been granted on.
+func setAppliedGrantObjects(appliedGrants []v1alpha1.Grant, database string, keys []grantKey) []v1alpha1.Grant {
+	objects := groupGrantKeys(keys)
+	for index := range objects {
+		objects[index].MatchType = MatchTypeExact
 	}
-	if a.Identifier != b.Identifier {
-		return false
+
+	var result []v1alpha1.Grant
+	for _, grant := range appliedGrants {
+		if grant.Database != database {
+			result = append(result, grant)
+		}
 	}
-	if a.WithGrantOption != b.WithGrantOption {
-		return false
+	if len(objects) > 0 {
+		result = append(result, v1alpha1.Grant{Database: database, Objects: objects})
 	}
-	return true
-}
 
-func subtractPrivilegeConjunction(a, b []v1alpha1.Privilege) []v1alpha1.Privilege {
-	buffer := map[v1alpha1.Privilege]int{}
-	var result []v1alpha1.Privilege
-	var found bool
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Database < result[j].Database
+	})
+	return result
+}
 
-	for _, privilegeA := range a {
-		found = false
-		for _, privilegeB := range b {
-			if privilegeA == privilegeB {
-				found = true
-				break
-			}
-		}
-		if !found {
-			buffer[privilegeA] = 1
+func getGrantKeys(grantObjects []v1alpha1.GrantObject) map[grantKey]bool {
+	keys := map[grantKey]bool{}
+	for _, grantObject := range grantObjects {
+		for _, privilege := range grantObject.Privileges {
+			keys[grantKey{
+				Type:            grantObject.Type,
+				Schema:          grantObject.Schema,
+				Table:           grantObject.Table,
+				Identifier:      grantObject.Identifier,
+				Signature:       grantObject.Signature,
+				Privilege:       privilege,
+				WithGrantOption: grantObject.WithGrantOption,
+			}] = true
 		}
 	}
+	return keys
+}
 
-	for key := range buffer {
-		result = append(result, key)
+// groupGrantKeys groups the privileges by their objects in a stable order, so that each object can be granted or
+// revoked with a single statement.
+func groupGrantKeys(keys []grantKey) []v1alpha1.GrantObject {
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	var grantObjects []v1alpha1.GrantObject
+	indices := map[grantKey]int{}
+	for _, key := range keys {
+		privilege := key.Privilege
+		key.Privilege = ""
+
+		index, ok := indices[key]
+		if !ok {
+			index = len(grantObjects)
+			indices[key] = index
+			grantObjects = append(grantObjects, v1alpha1.GrantObject{
+				Type:            key.Type,
+				Schema:          key.Schema,
+				Table:           key.Table,
+				Identifier:      key.Identifier,
+				Signature:       key.Signature,
+				WithGrantOption: key.WithGrantOption,
+			})
+		}
+		grantObjects[index].Privileges = append(grantObjects[index].Privileges, privilege)
 	}
-	return result
+
+	return grantObjects
 }
 
-// In case the GrantObjects a and b don't have the same target, there is still
-// the possibility for b (table) including a (columns). This function checks for
-// this case.
-func grantObjectIncludesTarget(a, b *v1alpha1.GrantObject) bool {
-	if a.Type == postgres.COLUMN && b.Type == postgres.TABLE {
-		if a.Schema != b.Schema {
-			return false
-		}
-		if a.Table != b.Identifier {
-			return false
-		}
-		if a.WithGrantOption != b.WithGrantOption {
-			return false
-		}
-		return true
-	}
-	return false
-}
-
-func getGrantQueries() map[string]string {
-	return map[string]string{
-		postgres.TABLE: `
-        select
-		'TABLE' as type,
-		table_schema as schema,
-		'' as table,
-        table_name as identifier,
-        array_agg(cast(privilege_type AS text)) as privileges,
-        is_grantable::bool as withGrantOption
-        from information_schema.role_table_grants
-        WHERE grantee=$1
-        GROUP BY identifier, schema, withGrantOption`,
-
-		"SCHEMA": `
-        SELECT
-        type,
-		schema,
-		'' as table,
-        identifier,
-        array_agg(privileges),
-        withGrantOption
-        FROM
-        (SELECT
-        nspname as identifier,
-        'SCHEMA' as type,
-        'public' as schema,
-        (aclexplode(nspacl)).privilege_type as privileges,
-        (aclexplode(nspacl)).is_grantable as withGrantOption,
-        (aclexplode(nspacl)).grantee as grantee
-        FROM pg_catalog.pg_namespace) sub
-        WHERE grantee = (SELECT oid FROM pg_catalog.pg_roles where rolname=$1)
-        GROUP BY identifier, type, schema, withGrantOption`,
-
-		"COLUMN": `
-	    Select
-			t1.type,
-			t1.schema,
-			t1.table,
-			t1.identifier,
-			array_agg(t1.privileges),
-			t1.withgrantoption
-			from
-		(select
-		        'COLUMN' as type,
-		        table_schema as schema,
-		        table_name as table,
-		        column_name as identifier,
-		        cast(privilege_type AS text) as privileges,
-		        is_grantable::bool as withGrantOption
-		        from information_schema.column_privileges
-		        where grantee=$1
-		except
-		select
-		        'COLUMN' as type,
-		        t.table_schema as schema,
-		        t.table_name as table,
-		        c.column_name as identifier,
-		        cast(t.privilege_type AS text) as privileges,
-		        t.is_grantable::bool as withGrantOption
-		        from information_schema.role_table_grants t
-		        LEFT JOIN information_schema.columns c
-		        on (t.table_name = c.table_name
-		        AND t.table_schema = c.table_schema)
-		        WHERE grantee=$1)
-		as t1
-		group by t1.type, t1.schema, t1.table, t1.identifier, t1.withgrantoption`,
-
-		"FUNCTION": `
+// grantQueries holds the queries, that read the current privileges of the role per grant type. They are combined
+// into a single query, therefore all of them return the same columns. The privileges of the owner of an object are
+// implicit and can't be managed by grants, they are omitted.
+var grantQueries = map[string]string{
+	postgres.TABLE: `
 		select
-        'FUNCTION' as type,
-        routine_schema as schema,
-        '' as table_name,
-        routine_name as identifier,
-		array_agg('EXECUTE'::varchar) as privileges,
-        is_grantable::bool as withGrantOption
-        from information_schema.role_routine_grants
-        WHERE grantee=$1
-        GROUP BY identifier, type, schema, table_name, withGrantOption`,
-
-		"SEQUENCE": `
-        select
-			sq.type,
-			sq.schema,
+			'TABLE' as type,
+			n.nspname::text as schema,
 			'' as table_name,
-			sq.identifier,
-			array_agg(sq.privileges) as privileges,
-			sq.withGrantOption
-		from (
-			select
-			    'SEQUENCE' as type,
-			    nspname as schema,
-			    relname as identifier,
-			    (aclexplode(relacl)).privilege_type as privileges,
-			    (aclexplode(relacl)).is_grantable as withGrantOption,
-			    (aclexplode(relacl)).grantee as grantee
-			from pg_class cl
-			join pg_namespace nsp on (cl.relnamespace = nsp.oid)
-			join pg_sequence sq on (cl.oid = sq.seqrelid)
-			where relkind='S'
-		) as sq
-		join pg_authid au on (sq.grantee = au.oid)
-		where rolname=$1
-		GROUP BY identifier, type, schema, table_name, withGrantOption`,
-	}
+			c.relname::text as identifier,
+			'' as signature,
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_class c
+		join pg_catalog.pg_namespace n on (n.oid = c.relnamespace)
+		cross join lateral aclexplode(c.relacl) a
+		where c.relkind in ('r', 'p', 'v', 'm', 'f')
+		and a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> c.relowner`,
+
+	postgres.COLUMN: `
+		select
+			'COLUMN',
+			n.nspname::text,
+			c.relname::text,
+			at.attname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_attribute at
+		join pg_catalog.pg_class c on (c.oid = at.attrelid)
+		join pg_catalog.pg_namespace n on (n.oid = c.relnamespace)
+		cross join lateral aclexplode(at.attacl) a
+		where at.attnum > 0
+		and not at.attisdropped
+		and a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> c.relowner`,
+
+	postgres.SEQUENCE: `
+		select
+			'SEQUENCE',
+			n.nspname::text,
+			'',
+			c.relname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_class c
+		join pg_catalog.pg_namespace n on (n.oid = c.relnamespace)
+		cross join lateral aclexplode(c.relacl) a
+		where c.relkind = 'S'
+		and a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> c.relowner`,
+
+	postgres.SCHEMA: `
+		select
+			'SCHEMA',
+			'',
+			'',
+			n.nspname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_namespace n
+		cross join lateral aclexplode(n.nspacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> n.nspowner`,
+
+	postgres.FUNCTION: `
+		select
+			case p.prokind when 'p' then 'PROCEDURE' else 'FUNCTION' end,
+			n.nspname::text,
+			'',
+			p.proname::text,
+			pg_catalog.pg_get_function_identity_arguments(p.oid),
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_proc p
+		join pg_catalog.pg_namespace n on (n.oid = p.pronamespace)
+		cross join lateral aclexplode(p.proacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> p.proowner`,
+
+	postgres.DATABASE: `
+		select
+			'DATABASE',
+			'',
+			'',
+			d.datname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_database d
+		cross join lateral aclexplode(d.datacl) a
+		where d.datname = current_database()
+		and a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> d.datdba`,
+
+	postgres.TYPE: `
+		select
+			case t.typtype when 'd' then 'DOMAIN' else 'TYPE' end,
+			n.nspname::text,
+			'',
+			t.typname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_type t
+		join pg_catalog.pg_namespace n on (n.oid = t.typnamespace)
+		cross join lateral aclexplode(t.typacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> t.typowner`,
+
+	postgres.LANGUAGE: `
+		select
+			'LANGUAGE',
+			'',
+			'',
+			l.lanname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_language l
+		cross join lateral aclexplode(l.lanacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> l.lanowner`,
+
+	postgres.FOREIGN_DATA_WRAPPER: `
+		select
+			'FOREIGN_DATA_WRAPPER',
+			'',
+			'',
+			f.fdwname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_foreign_data_wrapper f
+		cross join lateral aclexplode(f.fdwacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> f.fdwowner`,
+
+	postgres.FOREIGN_SERVER: `
+		select
+			'FOREIGN_SERVER',
+			'',
+			'',
+			s.srvname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_foreign_server s
+		cross join lateral aclexplode(s.srvacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> s.srvowner`,
+
+	postgres.TABLESPACE: `
+		select
+			'TABLESPACE',
+			'',
+			'',
+			t.spcname::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_tablespace t
+		cross join lateral aclexplode(t.spcacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> t.spcowner`,
+
+	postgres.LARGE_OBJECT: `
+		select
+			'LARGE_OBJECT',
+			'',
+			'',
+			l.oid::text,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_largeobject_metadata l
+		cross join lateral aclexplode(l.lomacl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)
+		and a.grantee <> l.lomowner`,
+
+	postgres.PARAMETER: `
+		select
+			'PARAMETER',
+			'',
+			'',
+			p.parname,
+			'',
+			a.privilege_type,
+			a.is_grantable
+		from pg_catalog.pg_parameter_acl p
+		cross join lateral aclexplode(p.paracl) a
+		where a.grantee = (select oid from pg_catalog.pg_roles where rolname = $1)`,
 }
 
 func (r *Repository) createGrantQuery(ctx context.Context, grantTarget *v1alpha1.GrantObject) (string, error) {
@@ -641,7 +977,7 @@ func (r *Repository) createGrantQuery(ctx context.Context, grantTarget *v1alpha1
 			postgres.SanitizeString(grantTarget.Identifier),
 			postgres.SanitizeString(grantTarget.Schema),
 			postgres.SanitizeString(grantTarget.Table),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 	case postgres.TABLE:
 		query = fmt.Sprintf(
@@ -649,7 +985,7 @@ func (r *Repository) createGrantQuery(ctx context.Context, grantTarget *v1alpha1
 			getJoinedPrivileges(ctx, grantTarget),
 			postgres.SanitizeString(grantTarget.Schema),
 			postgres.SanitizeString(grantTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	case postgres.SCHEMA:
@@ -657,16 +993,18 @@ func (r *Repository) createGrantQuery(ctx context.Context, grantTarget *v1alpha1
 			"GRANT %s ON  SCHEMA %s TO %s",
 			getJoinedPrivileges(ctx, grantTarget),
 			postgres.SanitizeString(grantTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
-	case postgres.FUNCTION:
+	case postgres.FUNCTION, postgres.PROCEDURE:
 		query = fmt.Sprintf(
-			"GRANT %s ON FUNCTION %s.%s TO %s",
+			"GRANT %s ON %s %s.%s(%s) TO %s",
 			getJoinedPrivileges(ctx, grantTarget),
+			grantTarget.Type,
 			postgres.SanitizeString(grantTarget.Schema),
 			postgres.SanitizeString(grantTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			grantTarget.Signature,
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	case postgres.SEQUENCE:
@@ -675,7 +1013,28 @@ func (r *Repository) createGrantQuery(ctx context.Context, grantTarget *v1alpha1
 			getJoinedPrivileges(ctx, grantTarget),
 			postgres.SanitizeString(grantTarget.Schema),
 			postgres.SanitizeString(grantTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
+		)
+
+	case postgres.DATABASE:
+		query = fmt.Sprintf(
+			"GRANT %s ON DATABASE %s TO %s",
+			getJoinedPrivileges(ctx, grantTarget),
+			postgres.SanitizeString(grantTarget.Identifier),
+			postgres.SanitizeString(r.role.PostgresName()),
+		)
+
+	case postgres.TYPE, postgres.DOMAIN, postgres.LANGUAGE, postgres.FOREIGN_DATA_WRAPPER,
+		postgres.FOREIGN_SERVER, postgres.TABLESPACE, postgres.LARGE_OBJECT, postgres.PARAMETER:
+		target, err := getGrantObjectTarget(grantTarget)
+		if err != nil {
+			return "", err
+		}
+		query = fmt.Sprintf(
+			"GRANT %s ON %s TO %s",
+			getJoinedPrivileges(ctx, grantTarget),
+			target,
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	default:
@@ -701,7 +1060,7 @@ func (r *Repository) createRevokeQuery(ctx context.Context, revokeTarget *v1alph
 			postgres.SanitizeString(revokeTarget.Identifier),
 			postgres.SanitizeString(revokeTarget.Schema),
 			postgres.SanitizeString(revokeTarget.Table),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 	case postgres.TABLE:
 		query = fmt.Sprintf(
@@ -709,7 +1068,7 @@ func (r *Repository) createRevokeQuery(ctx context.Context, revokeTarget *v1alph
 			getJoinedPrivileges(ctx, revokeTarget),
 			postgres.SanitizeString(revokeTarget.Schema),
 			postgres.SanitizeString(revokeTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	case postgres.SCHEMA:
@@ -717,16 +1076,18 @@ func (r *Repository) createRevokeQuery(ctx context.Context, revokeTarget *v1alph
 			"REVOKE %s ON SCHEMA %s FROM %s",
 			getJoinedPrivileges(ctx, revokeTarget),
 			postgres.SanitizeString(revokeTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
-	case postgres.FUNCTION:
+	case postgres.FUNCTION, postgres.PROCEDURE:
 		query = fmt.Sprintf(
-			"REVOKE %s ON FUNCTION %s.%s FROM %s",
+			"REVOKE %s ON %s %s.%s(%s) FROM %s",
 			getJoinedPrivileges(ctx, revokeTarget),
+			revokeTarget.Type,
 			postgres.SanitizeString(revokeTarget.Schema),
 			postgres.SanitizeString(revokeTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			revokeTarget.Signature,
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	case postgres.SEQUENCE:
@@ -735,7 +1096,28 @@ func (r *Repository) createRevokeQuery(ctx context.Context, revokeTarget *v1alph
 			getJoinedPrivileges(ctx, revokeTarget),
 			postgres.SanitizeString(revokeTarget.Schema),
 			postgres.SanitizeString(revokeTarget.Identifier),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
+		)
+
+	case postgres.DATABASE:
+		query = fmt.Sprintf(
+			"REVOKE %s ON DATABASE %s FROM %s",
+			getJoinedPrivileges(ctx, revokeTarget),
+			postgres.SanitizeString(revokeTarget.Identifier),
+			postgres.SanitizeString(r.role.PostgresName()),
+		)
+
+	case postgres.TYPE, postgres.DOMAIN, postgres.LANGUAGE, postgres.FOREIGN_DATA_WRAPPER,
+		postgres.FOREIGN_SERVER, postgres.TABLESPACE, postgres.LARGE_OBJECT, postgres.PARAMETER:
+		target, err := getGrantObjectTarget(revokeTarget)
+		if err != nil {
+			return "", err
+		}
+		query = fmt.Sprintf(
+			"REVOKE %s ON %s FROM %s",
+			getJoinedPrivileges(ctx, revokeTarget),
+			target,
+			postgres.SanitizeString(r.role.PostgresName()),
 		)
 
 	default:
@@ -750,7 +1132,7 @@ func getJoinedPrivileges(ctx context.Context, grantObject *v1alpha1.GrantObject)
 	privileges := make([]string, len(grantObject.Privileges))
 
 	for index, privilege := range grantObject.Privileges {
-		privileges[index] = string(privilege)
+		privileges[index] = strings.ReplaceAll(string(privilege), "_", " ")
 	}
 
 	log.FromContext(ctx).Info("computed privileges", "privileges", privileges)
@@ -758,27 +1140,263 @@ func getJoinedPrivileges(ctx context.Context, grantObject *v1alpha1.GrantObject)
 	return strings.Join(privileges, ", ")
 }
 
-// TODO: move to database package
-func (r *Repository) GetDatabaseNames(ctx context.Context) ([]string, error) {
-	var databaseNames []string
+// getMatchPattern returns the anchored regular expression, that matches the given pattern according to the match
+// type. Literal characters are escaped, so that names containing e.g. "." or "$" match only themselves.
+func getMatchPattern(matchType string, pattern string) string {
+	switch matchType {
+	case MatchTypeExact:
+		return "^" + regexp.QuoteMeta(pattern) + "$"
+	case MatchTypeGlob:
+		var builder strings.Builder
+		for _, char := range pattern {
+			switch char {
+			case '*':
+				builder.WriteString(".*")
+			case '?':
+				builder.WriteString(".")
+			default:
+				builder.WriteString(regexp.QuoteMeta(string(char)))
+			}
+		}
+		return "^" + builder.String() + "$"
+	default:
+		return "^(?:" + pattern + ")$"
+	}
+}
+
+// excludeGrantObjects removes the expanded grant objects, whose identifiers match one of the exclusion patterns of
+// the given grant object. The patterns are evaluated by PostgreSQL, so that they behave like the other patterns.
+func (r *Repository) excludeGrantObjects(ctx context.Context, grantObject v1alpha1.GrantObject, expanded []v1alpha1.GrantObject) ([]v1alpha1.GrantObject, error) {
+	if len(grantObject.Exclude) == 0 || len(expanded) == 0 {
+		return expanded, nil
+	}
+
+	patterns := getExcludePatterns(grantObject)
+
+	identifiers := make([]string, len(expanded))
+	for index, entry := range expanded {
+		identifiers[index] = entry.Identifier
+	}
+
+	var excluded []string
+	err := r.conn.QueryRow(
+		ctx,
+		`select coalesce(array_agg(i), '{}') from unnest($1::text[]) as i where i ~ any($2::text[])`,
+		identifiers,
+		patterns,
+	).Scan(&excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	return removeExcludedGrantObjects(expanded, excluded), nil
+}
+
+// getExcludePatterns returns the exclusion patterns of the grant object as regular expressions, interpreted
+// according to its match type.
+func getExcludePatterns(grantObject v1alpha1.GrantObject) []string {
+	patterns := make([]string, len(grantObject.Exclude))
+	for index, pattern := range grantObject.Exclude {
+		patterns[index] = getMatchPattern(grantObject.MatchType, pattern)
+	}
+	return patterns
+}
+
+// removeExcludedGrantObjects removes the grant objects, whose identifiers are contained within the excluded
+// identifiers.
+func removeExcludedGrantObjects(expanded []v1alpha1.GrantObject, excluded []string) []v1alpha1.GrantObject {
+	isExcluded := map[string]bool{}
+	for _, identifier := range excluded {
+		isExcluded[identifier] = true
+	}
+
+	var result []v1alpha1.GrantObject
+	for _, entry := range expanded {
+		if !isExcluded[entry.Identifier] {
+			result = append(result, entry)
+		}
+	}
+
+	return result
+}
+
+// expandRelations expands the given grant object to all matching relations of the relkinds, that belong to the
+// type of the grant object. All relations are granted as TABLE. If requested, the partitions of matching
+// partitioned tables are included, even if they don't match themselves.
+func (r *Repository) expandRelations(ctx context.Context, grantObject v1alpha1.GrantObject) ([]v1alpha1.GrantObject, error) {
+	relkinds := map[string][]string{
+		postgres.TABLE:             {"r", "p"},
+		postgres.VIEW:              {"v"},
+		postgres.MATERIALIZED_VIEW: {"m"},
+		postgres.FOREIGN_TABLE:     {"f"},
+	}
+
+	rows, err := r.conn.Query(
+		ctx,
+		`with recursive relations as (
+			select c.oid, n.nspname, c.relname
+			from pg_catalog.pg_class c
+			join pg_catalog.pg_namespace n on (n.oid = c.relnamespace)
+			where n.nspname ~ $1
+			and c.relname ~ $2
+			and c.relkind::text = any($3)
+		union
+			select c.oid, n.nspname, c.relname
+			from relations p
+			join pg_catalog.pg_inherits i on (i.inhparent = p.oid)
+			join pg_catalog.pg_class c on (c.oid = i.inhrelid)
+			join pg_catalog.pg_namespace n on (n.oid = c.relnamespace)
+			where $4 and c.relispartition
+		)
+		select nspname, relname from relations`,
+		getMatchPattern(grantObject.MatchType, grantObject.Schema),
+		getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+		relkinds[grantObject.Type],
+		grantObject.IncludePartitions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var expanded []v1alpha1.GrantObject
+	for rows.Next() {
+		relation := grantObject
+		relation.Type = postgres.TABLE
+		if err = rows.Scan(&relation.Schema, &relation.Identifier); err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, relation)
+	}
+
+	return expanded, rows.Err()
+}
+
+// expandRoutines expands the given grant object to all matching functions and procedures. Each overload is
+// returned separately, identified by its identity arguments. If a signature is given, only the overload with the
+// equivalent argument types is matched.
+func (r *Repository) expandRoutines(ctx context.Context, grantObject v1alpha1.GrantObject) ([]v1alpha1.GrantObject, error) {
+	prokinds := map[string][]string{
+		postgres.FUNCTION:  {"f", "a", "w"},
+		postgres.PROCEDURE: {"p"},
+		postgres.ROUTINE:   {"f", "a", "w", "p"},
+	}
 
 	rows, err := r.conn.Query(
 		ctx,
-		"select datname from pg_database where datistemplate = 'f'",
+		`select
+			p.proname,
+			pg_catalog.pg_get_function_identity_arguments(p.oid),
+			case p.prokind when 'p' then 'PROCEDURE' else 'FUNCTION' end
+		from pg_catalog.pg_proc p
+		join pg_catalog.pg_namespace n on (n.oid = p.pronamespace)
+		where n.nspname ~ $1
+		and p.proname ~ $2
+		and p.prokind::text = any($3)
+		and ($4 = '' or p.oid = to_regprocedure(format('%I.%I(%s)', n.nspname, p.proname, $4)))`,
+		getMatchPattern(grantObject.MatchType, grantObject.Schema),
+		getMatchPattern(grantObject.MatchType, grantObject.Identifier),
+		prokinds[grantObject.Type],
+		grantObject.Signature,
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	var expanded []v1alpha1.GrantObject
+	for rows.Next() {
+		routine := grantObject
+		if err = rows.Scan(&routine.Identifier, &routine.Signature, &routine.Type); err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, routine)
+	}
+
+	return expanded, rows.Err()
+}
+
+// getGrantObjectTarget returns the object of a GRANT or REVOKE statement, e.g. "FOREIGN SERVER foo".
+func getGrantObjectTarget(grantObject *v1alpha1.GrantObject) (string, error) {
+	switch grantObject.Type {
+	case postgres.TYPE, postgres.DOMAIN:
+		return fmt.Sprintf(
+			"%s %s.%s",
+			grantObject.Type,
+			postgres.SanitizeString(grantObject.Schema),
+			postgres.SanitizeString(grantObject.Identifier),
+		), nil
+	case postgres.LARGE_OBJECT:
+		// large objects are identified by their OID, which can't be quoted
+		oid, err := strconv.ParseUint(grantObject.Identifier, 10, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid large object OID '%s'", grantObject.Identifier)
+		}
+		return fmt.Sprintf("LARGE OBJECT %d", oid), nil
+	default:
+		return fmt.Sprintf(
+			"%s %s",
+			strings.ReplaceAll(grantObject.Type, "_", " "),
+			postgres.SanitizeString(grantObject.Identifier),
+		), nil
+	}
+}
+
+// GetGrantDatabaseNames returns the databases, whose grants have to be reconciled. These are the databases, that
+// are referenced by the grants and default privileges of the role, the database of the connection, which holds the
+// grants on shared objects, and all databases, in which the role currently holds privileges. The latter are found
+// by the ACL dependencies of the role within pg_shdepend, so that undesired grants are revoked, even if the
+// database is not referenced anymore. Databases, that don't exist or don't allow connections, are skipped.
+func (r *Repository) GetGrantDatabaseNames(ctx context.Context) ([]string, error) {
+	referenced := []string{r.connection.Spec.Database}
+	for _, grant := range r.role.Spec.Grants {
+		referenced = append(referenced, grant.Database)
+	}
+	for _, defaultPrivilege := range r.role.Spec.DefaultPrivileges {
+		referenced = append(referenced, defaultPrivilege.Database)
+	}
+
+	var databaseNames []string
+
+	rows, err := r.conn.Query(
+		ctx,
+		`select d.datname
+		from pg_catalog.pg_database d
+		where not d.datistemplate
+		and d.datallowconn
+		and (
+			d.datname = any($2)
+			or d.oid in (
+				select case when s.classid = 'pg_catalog.pg_database'::regclass then s.objid else s.dbid end
+				from pg_catalog.pg_shdepend s
+				where s.refclassid = 'pg_catalog.pg_authid'::regclass
+				and s.refobjid = (select oid from pg_catalog.pg_roles where rolname = $1)
+				and s.deptype = 'a'
+			)
+		)
+		order by d.datname`,
+		r.role.PostgresName(),
+		referenced,
+	)
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var name string
 		err = rows.Scan(&name)
 
 		if err != nil {
-			return nil, err
+			return nil, r.newRepositoryError(err)
 		}
 
 		databaseNames = append(databaseNames, name)
 	}
+
+	if err = rows.Err(); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
 	return databaseNames, nil
 }
@@ -0,0 +1,519 @@
+package role
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetGrantSymmetricDifference(t *testing.T) {
+	tests := []struct {
+		name          string
+		desired       []v1alpha1.GrantObject
+		current       []v1alpha1.GrantObject
+		wantMissing   []v1alpha1.GrantObject
+		wantUndesired []v1alpha1.GrantObject
+	}{
+		{
+			name: "up to date",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "INSERT"}},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"INSERT"}},
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			},
+		},
+		{
+			name: "missing and undesired privileges on the same object",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "UPDATE", "INSERT"}},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"DELETE"}},
+			},
+			wantMissing: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"INSERT", "UPDATE"}},
+			},
+			wantUndesired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"DELETE"}},
+			},
+		},
+		{
+			name: "grant option added",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}, WithGrantOption: true},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+			wantMissing: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}, WithGrantOption: true},
+			},
+			wantUndesired: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+		},
+		{
+			name: "grant option removed",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}, WithGrantOption: true},
+			},
+			wantMissing: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+			wantUndesired: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}, WithGrantOption: true},
+			},
+		},
+		{
+			name: "overloads are distinguished by their signature",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.FUNCTION, Schema: "public", Identifier: "total", Signature: "integer", Privileges: []v1alpha1.Privilege{"EXECUTE"}},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.FUNCTION, Schema: "public", Identifier: "total", Signature: "integer", Privileges: []v1alpha1.Privilege{"EXECUTE"}},
+				{Type: postgres.FUNCTION, Schema: "public", Identifier: "total", Signature: "text", Privileges: []v1alpha1.Privilege{"EXECUTE"}},
+			},
+			wantUndesired: []v1alpha1.GrantObject{
+				{Type: postgres.FUNCTION, Schema: "public", Identifier: "total", Signature: "text", Privileges: []v1alpha1.Privilege{"EXECUTE"}},
+			},
+		},
+		{
+			name: "column privileges are included within table privileges",
+			desired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+				{Type: postgres.COLUMN, Schema: "public", Table: "users", Identifier: "email", Privileges: []v1alpha1.Privilege{"SELECT", "UPDATE"}},
+			},
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			},
+			wantMissing: []v1alpha1.GrantObject{
+				{Type: postgres.COLUMN, Schema: "public", Table: "users", Identifier: "email", Privileges: []v1alpha1.Privilege{"UPDATE"}},
+			},
+		},
+		{
+			name: "all privileges undesired",
+			current: []v1alpha1.GrantObject{
+				{Type: postgres.SEQUENCE, Schema: "public", Identifier: "users_id_seq", Privileges: []v1alpha1.Privilege{"USAGE"}},
+				{Type: postgres.DATABASE, Identifier: "app", Privileges: []v1alpha1.Privilege{"CONNECT"}},
+			},
+			wantUndesired: []v1alpha1.GrantObject{
+				{Type: postgres.DATABASE, Identifier: "app", Privileges: []v1alpha1.Privilege{"CONNECT"}},
+				{Type: postgres.SEQUENCE, Schema: "public", Identifier: "users_id_seq", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			missing, undesired := getGrantSymmetricDifference(test.desired, test.current)
+			if !reflect.DeepEqual(missing, test.wantMissing) {
+				t.Errorf("missing grants = %+v, want %+v", missing, test.wantMissing)
+			}
+			if !reflect.DeepEqual(undesired, test.wantUndesired) {
+				t.Errorf("undesired grants = %+v, want %+v", undesired, test.wantUndesired)
+			}
+		})
+	}
+}
+
+func TestGroupGrantKeys(t *testing.T) {
+	keys := []grantKey{
+		{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privilege: "UPDATE"},
+		{Type: postgres.SCHEMA, Identifier: "public", Privilege: "USAGE"},
+		{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privilege: "SELECT"},
+		{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privilege: "SELECT", WithGrantOption: true},
+	}
+
+	want := []v1alpha1.GrantObject{
+		{Type: postgres.SCHEMA, Identifier: "public", Privileges: []v1alpha1.Privilege{"USAGE"}},
+		{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "UPDATE"}},
+		{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}, WithGrantOption: true},
+	}
+
+	if got := groupGrantKeys(keys); !reflect.DeepEqual(got, want) {
+		t.Errorf("groupGrantKeys() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateGrantAndRevokeQuery(t *testing.T) {
+	repository := Repository{role: &v1alpha1.Role{ObjectMeta: metav1.ObjectMeta{Name: "app"}}}
+
+	tests := []struct {
+		name       string
+		object     v1alpha1.GrantObject
+		wantGrant  string
+		wantRevoke string
+	}{
+		{
+			name:       "table with grant option",
+			object:     v1alpha1.GrantObject{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "UPDATE"}, WithGrantOption: true},
+			wantGrant:  `GRANT SELECT, UPDATE ON "public"."users" TO "app" WITH GRANT OPTION`,
+			wantRevoke: `REVOKE SELECT, UPDATE ON "public"."users" FROM "app"`,
+		},
+		{
+			name:       "column",
+			object:     v1alpha1.GrantObject{Type: postgres.COLUMN, Schema: "public", Table: "users", Identifier: "email", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			wantGrant:  `GRANT SELECT ("email") ON TABLE "public"."users" TO "app"`,
+			wantRevoke: `REVOKE SELECT ("email") ON TABLE "public"."users" FROM "app"`,
+		},
+		{
+			name:       "function overload",
+			object:     v1alpha1.GrantObject{Type: postgres.FUNCTION, Schema: "public", Identifier: "total", Signature: "integer, text", Privileges: []v1alpha1.Privilege{"EXECUTE"}},
+			wantGrant:  `GRANT EXECUTE ON FUNCTION "public"."total"(integer, text) TO "app"`,
+			wantRevoke: `REVOKE EXECUTE ON FUNCTION "public"."total"(integer, text) FROM "app"`,
+		},
+		{
+			name:       "parameter",
+			object:     v1alpha1.GrantObject{Type: postgres.PARAMETER, Identifier: "myext.setting", Privileges: []v1alpha1.Privilege{"SET", "ALTER_SYSTEM"}},
+			wantGrant:  `GRANT SET, ALTER SYSTEM ON PARAMETER "myext.setting" TO "app"`,
+			wantRevoke: `REVOKE SET, ALTER SYSTEM ON PARAMETER "myext.setting" FROM "app"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			grant, err := repository.createGrantQuery(context.Background(), &test.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if grant != test.wantGrant {
+				t.Errorf("createGrantQuery() = %q, want %q", grant, test.wantGrant)
+			}
+
+			revoke, err := repository.createRevokeQuery(context.Background(), &test.object)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoke != test.wantRevoke {
+				t.Errorf("createRevokeQuery() = %q, want %q", revoke, test.wantRevoke)
+			}
+		})
+	}
+}
+
+func TestFilterAppliedGrants(t *testing.T) {
+	tests := []struct {
+		name      string
+		undesired []v1alpha1.GrantObject
+		applied   []v1alpha1.GrantObject
+		want      []v1alpha1.GrantObject
+	}{
+		{
+			name: "nothing applied",
+			undesired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			},
+		},
+		{
+			name: "only applied privileges are revoked",
+			undesired: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "INSERT"}},
+				{Type: postgres.TABLE, Schema: "public", Identifier: "orders", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			},
+			applied: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT", "UPDATE"}},
+			},
+			want: []v1alpha1.GrantObject{
+				{Type: postgres.TABLE, Schema: "public", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}},
+			},
+		},
+		{
+			name: "privileges with another grant option are kept",
+			undesired: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}, WithGrantOption: true},
+			},
+			applied: []v1alpha1.GrantObject{
+				{Type: postgres.SCHEMA, Identifier: "app", Privileges: []v1alpha1.Privilege{"USAGE"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := filterAppliedGrants(test.undesired, test.applied); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("filterAppliedGrants() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetAppliedGrantKeys(t *testing.T) {
+	users := v1alpha1.GrantObject{Type: postgres.TABLE, Schema: "app", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}}
+	orders := v1alpha1.GrantObject{Type: postgres.TABLE, Schema: "app", Identifier: "orders", Privileges: []v1alpha1.Privilege{"SELECT"}}
+	// granted by a migration, after kubepost has reconciled the role
+	audit := v1alpha1.GrantObject{Type: postgres.TABLE, Schema: "app", Identifier: "audit", Privileges: []v1alpha1.Privilege{"SELECT"}}
+
+	tests := []struct {
+		name    string
+		applied []v1alpha1.GrantObject
+		granted []v1alpha1.GrantObject
+		current []v1alpha1.GrantObject
+		want    []v1alpha1.GrantObject
+	}{
+		{
+			name:    "granted privileges are tracked",
+			granted: []v1alpha1.GrantObject{users},
+			current: []v1alpha1.GrantObject{users},
+			want:    []v1alpha1.GrantObject{users},
+		},
+		{
+			name:    "privileges of others are not tracked",
+			applied: []v1alpha1.GrantObject{users},
+			current: []v1alpha1.GrantObject{users, audit},
+			want:    []v1alpha1.GrantObject{users},
+		},
+		{
+			name:    "failed grants are not tracked",
+			granted: []v1alpha1.GrantObject{users, orders},
+			current: []v1alpha1.GrantObject{users},
+			want:    []v1alpha1.GrantObject{users},
+		},
+		{
+			name:    "revoked privileges are not tracked anymore",
+			applied: []v1alpha1.GrantObject{users, orders},
+			current: []v1alpha1.GrantObject{orders},
+			want:    []v1alpha1.GrantObject{orders},
+		},
+		{
+			name:    "changed grant option",
+			applied: []v1alpha1.GrantObject{users},
+			granted: []v1alpha1.GrantObject{{Type: postgres.TABLE, Schema: "app", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}, WithGrantOption: true}},
+			current: []v1alpha1.GrantObject{{Type: postgres.TABLE, Schema: "app", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}, WithGrantOption: true}},
+			want:    []v1alpha1.GrantObject{{Type: postgres.TABLE, Schema: "app", Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}, WithGrantOption: true}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := groupGrantKeys(getAppliedGrantKeys(test.applied, test.granted, test.current))
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("getAppliedGrantKeys() = %+v, want %+v", got, test.want)
+			}
+
+			// privileges of others are never revoked, even if they match a removed grant
+			if revoked := filterAppliedGrants([]v1alpha1.GrantObject{audit}, got); revoked != nil {
+				t.Errorf("filterAppliedGrants() = %+v, want no privileges of others", revoked)
+			}
+		})
+	}
+}
+
+func TestSetAppliedGrantObjects(t *testing.T) {
+	users := grantKey{Type: postgres.TABLE, Schema: "app", Identifier: "users", Privilege: "SELECT"}
+	applied := []v1alpha1.Grant{
+		{Database: "app", Objects: []v1alpha1.GrantObject{{Type: postgres.TABLE, Schema: "app", Identifier: "orders", Privileges: []v1alpha1.Privilege{"SELECT"}}}},
+		{Database: "postgres", Objects: []v1alpha1.GrantObject{{Type: postgres.DATABASE, Identifier: "postgres", Privileges: []v1alpha1.Privilege{"CONNECT"}}}},
+	}
+
+	got := setAppliedGrantObjects(applied, "app", []grantKey{users})
+	want := []v1alpha1.Grant{
+		{Database: "app", Objects: []v1alpha1.GrantObject{{Type: postgres.TABLE, Schema: "app", MatchType: MatchTypeExact, Identifier: "users", Privileges: []v1alpha1.Privilege{"SELECT"}}}},
+		applied[1],
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("setAppliedGrantObjects() = %+v, want %+v", got, want)
+	}
+	if objects := getAppliedGrantObjects(got, "app"); len(objects) != 1 || objects[0].Identifier != "users" {
+		t.Errorf("getAppliedGrantObjects() = %+v", objects)
+	}
+
+	if got := setAppliedGrantObjects(applied, "postgres", nil); !reflect.DeepEqual(got, applied[:1]) {
+		t.Errorf("setAppliedGrantObjects() = %+v, want the database without privileges to be removed", got)
+	}
+}
+
+func TestAdditiveDefaultPrivileges(t *testing.T) {
+	current := map[v1alpha1.Privilege]bool{"SELECT": true, "INSERT": true, "UPDATE": true}
+	applied := map[v1alpha1.Privilege]bool{"SELECT": true, "INSERT": true}
+	desired := map[v1alpha1.Privilege]bool{"SELECT": true, "DELETE": true}
+
+	if got, want := subtractPrivileges(intersectPrivileges(current, applied), desired), []v1alpha1.Privilege{"INSERT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("revoked default privileges = %v, want %v", got, want)
+	}
+	if got, want := subtractPrivileges(desired, current), []v1alpha1.Privilege{"DELETE"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("granted default privileges = %v, want %v", got, want)
+	}
+}
+
+func TestGetMatchPattern(t *testing.T) {
+	tests := []struct {
+		name      string
+		matchType string
+		pattern   string
+		want      string
+		matches   []string
+		rejects   []string
+	}{
+		{
+			name:      "exact",
+			matchType: MatchTypeExact,
+			pattern:   "users",
+			want:      "^users$",
+			matches:   []string{"users"},
+			rejects:   []string{"users_archive", "old_users"},
+		},
+		{
+			name:      "exact quotes metacharacters",
+			matchType: MatchTypeExact,
+			pattern:   "a.b$c",
+			want:      `^a\.b\$c$`,
+			matches:   []string{"a.b$c"},
+			rejects:   []string{"axb$c", "a.b"},
+		},
+		{
+			name:      "exact quotes brackets and alternations",
+			matchType: MatchTypeExact,
+			pattern:   "t(1)|[x]*",
+			want:      `^t\(1\)\|\[x\]\*$`,
+			matches:   []string{"t(1)|[x]*"},
+			rejects:   []string{"t1", "x"},
+		},
+		{
+			name:      "glob wildcards",
+			matchType: MatchTypeGlob,
+			pattern:   "audit_*",
+			want:      "^audit_.*$",
+			matches:   []string{"audit_", "audit_log"},
+			rejects:   []string{"audit", "app_audit_log"},
+		},
+		{
+			name:      "glob single character",
+			matchType: MatchTypeGlob,
+			pattern:   "log_?",
+			want:      "^log_.$",
+			matches:   []string{"log_1"},
+			rejects:   []string{"log_", "log_12"},
+		},
+		{
+			name:      "glob quotes metacharacters",
+			matchType: MatchTypeGlob,
+			pattern:   "v1.*",
+			want:      `^v1\..*$`,
+			matches:   []string{"v1.users"},
+			rejects:   []string{"v1_users"},
+		},
+		{
+			name:      "regex alternation is anchored as a whole",
+			matchType: MatchTypeRegex,
+			pattern:   "users|orders",
+			want:      "^(?:users|orders)$",
+			matches:   []string{"users", "orders"},
+			rejects:   []string{"users_archive", "old_orders"},
+		},
+		{
+			name:      "regex is the default",
+			matchType: "",
+			pattern:   "t[0-9]+",
+			want:      "^(?:t[0-9]+)$",
+			matches:   []string{"t1", "t42"},
+			rejects:   []string{"t", "t1x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := getMatchPattern(test.matchType, test.pattern)
+			if got != test.want {
+				t.Fatalf("getMatchPattern() = %q, want %q", got, test.want)
+			}
+
+			// the patterns are evaluated by PostgreSQL, the ones above behave the same for Go regular expressions
+			pattern := regexp.MustCompile(got)
+			for _, identifier := range test.matches {
+				if !pattern.MatchString(identifier) {
+					t.Errorf("pattern %q does not match %q", got, identifier)
+				}
+			}
+			for _, identifier := range test.rejects {
+				if pattern.MatchString(identifier) {
+					t.Errorf("pattern %q matches %q", got, identifier)
+				}
+			}
+		})
+	}
+}
+
+func TestGetExcludePatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		grantObject v1alpha1.GrantObject
+		want        []string
+	}{
+		{
+			name:        "no exclusions",
+			grantObject: v1alpha1.GrantObject{MatchType: MatchTypeGlob, Identifier: "*"},
+			want:        []string{},
+		},
+		{
+			name:        "exclusions use the match type of the grant object",
+			grantObject: v1alpha1.GrantObject{MatchType: MatchTypeGlob, Identifier: "*", Exclude: []string{"audit_*", "tmp.?"}},
+			want:        []string{"^audit_.*$", `^tmp\..$`},
+		},
+		{
+			name:        "exact exclusions",
+			grantObject: v1alpha1.GrantObject{MatchType: MatchTypeExact, Identifier: "users", Exclude: []string{"a.b"}},
+			want:        []string{`^a\.b$`},
+		},
+		{
+			name:        "regex exclusions",
+			grantObject: v1alpha1.GrantObject{MatchType: MatchTypeRegex, Identifier: ".*", Exclude: []string{"audit|log"}},
+			want:        []string{"^(?:audit|log)$"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := getExcludePatterns(test.grantObject); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("getExcludePatterns() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRemoveExcludedGrantObjects(t *testing.T) {
+	expanded := []v1alpha1.GrantObject{
+		{Type: postgres.TABLE, Schema: "app", Identifier: "users"},
+		{Type: postgres.TABLE, Schema: "app", Identifier: "audit_log"},
+		{Type: postgres.TABLE, Schema: "app", Identifier: "orders"},
+	}
+
+	tests := []struct {
+		name     string
+		excluded []string
+		want     []v1alpha1.GrantObject
+	}{
+		{
+			name:     "nothing excluded",
+			excluded: []string{},
+			want:     expanded,
+		},
+		{
+			name:     "excluded identifiers are removed",
+			excluded: []string{"audit_log"},
+			want:     []v1alpha1.GrantObject{expanded[0], expanded[2]},
+		},
+		{
+			name:     "everything excluded",
+			excluded: []string{"users", "audit_log", "orders"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := removeExcludedGrantObjects(expanded, test.excluded); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("removeExcludedGrantObjects() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,7 @@ package role
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"github.com/jackc/pgconn"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/pkg/postgres"
 )
@@ -51,11 +49,12 @@ func (r *Repository) GetGroups(ctx context.Context) ([]v1alpha1.GroupGrantObject
         FROM pg_catalog.pg_auth_members m
         JOIN pg_catalog.pg_authid u on (m.roleid = u.oid)
         WHERE m.member = (select oid from pg_authid where rolname=$1)`,
-		r.role.ObjectMeta.Name,
+		r.role.PostgresName(),
 	)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		var group v1alpha1.GroupGrantObject
@@ -75,7 +74,7 @@ func (r *Repository) AddGroup(ctx context.Context, group *v1alpha1.GroupGrantObj
 	query := fmt.Sprintf(
 		"GRANT %s TO %s",
 		postgres.SanitizeString(group.Name),
-		postgres.SanitizeString(r.role.ObjectMeta.Name),
+		postgres.SanitizeString(r.role.PostgresName()),
 	)
 
 	if group.WithAdminOption {
@@ -88,22 +87,7 @@ func (r *Repository) AddGroup(ctx context.Context, group *v1alpha1.GroupGrantObj
 	)
 
 	if err != nil {
-		var pgErr *pgconn.PgError
-		errorCode := ""
-		errorMessage := ""
-		if errors.As(err, &pgErr) {
-			errorCode = pgErr.Code
-			errorMessage = pgErr.Message
-		}
-
-		return RepositoryError{
-			Role:                 r.role.ObjectMeta.Name,
-			Connection:           r.connection.ObjectMeta.Name,
-			Namespace:            r.role.ObjectMeta.Namespace,
-			Message:              err.Error(),
-			PostgresErrorCode:    errorCode,
-			PostgresErrorMessage: errorMessage,
-		}
+		return r.newRepositoryError(err)
 	}
 
 	return nil
@@ -116,27 +100,12 @@ func (r *Repository) RemoveGroup(ctx context.Context, group *v1alpha1.GroupGrant
 		fmt.Sprintf(
 			"REVOKE %s FROM %s",
 			postgres.SanitizeString(group.Name),
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
+			postgres.SanitizeString(r.role.PostgresName()),
 		),
 	)
 
 	if err != nil {
-		var pgErr *pgconn.PgError
-		errorCode := ""
-		errorMessage := ""
-		if errors.As(err, &pgErr) {
-			errorCode = pgErr.Code
-			errorMessage = pgErr.Message
-		}
-
-		return RepositoryError{
-			Role:                 r.role.ObjectMeta.Name,
-			Connection:           r.connection.ObjectMeta.Name,
-			Namespace:            r.role.ObjectMeta.Namespace,
-			Message:              err.Error(),
-			PostgresErrorCode:    errorCode,
-			PostgresErrorMessage: errorMessage,
-		}
+		return r.newRepositoryError(err)
 	}
 	return nil
 }
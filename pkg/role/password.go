@@ -0,0 +1,302 @@
+package role
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	CharsetAlphanumeric = "Alphanumeric"
+	CharsetPrintable    = "Printable"
+	CharsetCustom       = "Custom"
+
+	DefaultPasswordLength = 32
+	DefaultPasswordKey    = "password"
+
+	// RotatedAtAnnotation records the time of the last password generation on the password secret.
+	RotatedAtAnnotation = "postgres.kubepost.io/rotated-at"
+	// PendingRotatedAtAnnotation records the time of a rotated password, that has not been applied on all
+	// connections yet.
+	PendingRotatedAtAnnotation = "postgres.kubepost.io/pending-rotated-at"
+	// PendingPasswordKeySuffix is appended to the key of the password secret, that holds a rotated password
+	// until it has been applied on all connections.
+	PendingPasswordKeySuffix = ".pending"
+	// MaxRotationHistory is the number of rotations, that are kept within the status of the role.
+	MaxRotationHistory = 10
+
+	alphanumericCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// printable ASCII symbols without quotes, backslashes and whitespaces, so that the password can be embedded
+	// within SQL literals and most configuration formats as it is. Many of them are still shell metacharacters or
+	// reserved within URIs, passwords have to be quoted respectively percent-encoded there.
+	printableSymbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// GetGeneratedPasswordSelector returns the secret key selector of the generated password, or nil if the role
+// does not use password generation.
+func GetGeneratedPasswordSelector(role *v1alpha1.Role) *v1.SecretKeySelector {
+	generation := role.Spec.PasswordGeneration
+	if generation == nil {
+		return nil
+	}
+
+	name := generation.SecretName
+	if name == "" {
+		name = fmt.Sprintf("%s-password", role.ObjectMeta.Name)
+	}
+
+	key := generation.Key
+	if key == "" {
+		key = DefaultPasswordKey
+	}
+
+	return &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: name},
+		Key:                  key,
+	}
+}
+
+// ReconcileGeneratedPassword makes sure, that the secret holding the generated password of the role exists.
+// An existing password is only replaced if it is due for rotation, or if the secret or its key are removed.
+// Rotated passwords are stored under the pending key, until they have been applied on all connections, so that
+// the password within the secret keeps working in the meantime. The time of the last rotation is returned if
+// rotation is enabled, as well as whether the password has been rotated right now.
+func ReconcileGeneratedPassword(ctx context.Context, ctrlClient client.Client, role *v1alpha1.Role) (*time.Time, bool, error) {
+	selector := GetGeneratedPasswordSelector(role)
+	if selector == nil {
+		if role.Spec.Rotation != nil {
+			return nil, false, fmt.Errorf(
+				"rotation requires passwordGeneration for role '%s' in namespace '%s'",
+				role.ObjectMeta.Name,
+				role.ObjectMeta.Namespace,
+			)
+		}
+		return nil, false, nil
+	}
+
+	if role.Spec.Rotation != nil && role.Spec.Rotation.Interval.Duration <= 0 {
+		return nil, false, fmt.Errorf(
+			"rotation interval of role '%s' in namespace '%s' must be positive",
+			role.ObjectMeta.Name,
+			role.ObjectMeta.Namespace,
+		)
+	}
+
+	if role.Spec.Password != nil {
+		return nil, false, fmt.Errorf(
+			"password and passwordGeneration are mutually exclusive for role '%s' in namespace '%s'",
+			role.ObjectMeta.Name,
+			role.ObjectMeta.Namespace,
+		)
+	}
+
+	passwordSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      selector.Name,
+			Namespace: role.ObjectMeta.Namespace,
+		},
+	}
+
+	now := time.Now().Truncate(time.Second)
+	lastRotation := now
+	rotated := false
+
+	result, err := controllerutil.CreateOrUpdate(ctx, ctrlClient, passwordSecret, func() error {
+		// never take over secrets, that have not been created by kubepost
+		if !passwordSecret.ObjectMeta.CreationTimestamp.IsZero() && !metav1.IsControlledBy(passwordSecret, role) {
+			return fmt.Errorf(
+				"secret '%s' in namespace '%s' already exists and is not owned by role '%s'",
+				passwordSecret.ObjectMeta.Name,
+				passwordSecret.ObjectMeta.Namespace,
+				role.ObjectMeta.Name,
+			)
+		}
+
+		if passwordSecret.ObjectMeta.Labels == nil {
+			passwordSecret.ObjectMeta.Labels = map[string]string{}
+		}
+		passwordSecret.ObjectMeta.Labels["app.kubernetes.io/managed-by"] = "kubepost"
+		if passwordSecret.ObjectMeta.Annotations == nil {
+			passwordSecret.ObjectMeta.Annotations = map[string]string{}
+		}
+		passwordSecret.Type = v1.SecretTypeOpaque
+
+		if passwordSecret.Data == nil {
+			passwordSecret.Data = map[string][]byte{}
+		}
+
+		// a new password can be used right away, as nobody relies on the secret yet
+		if len(passwordSecret.Data[selector.Key]) == 0 {
+			password, err := generatePassword(role.Spec.PasswordGeneration)
+			if err != nil {
+				return err
+			}
+
+			passwordSecret.Data[selector.Key] = []byte(password)
+			delete(passwordSecret.Data, selector.Key+PendingPasswordKeySuffix)
+			passwordSecret.ObjectMeta.Annotations[RotatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+			delete(passwordSecret.ObjectMeta.Annotations, PendingRotatedAtAnnotation)
+			return controllerutil.SetControllerReference(role, passwordSecret, ctrlClient.Scheme())
+		}
+
+		lastRotation = getLastRotation(passwordSecret)
+		if role.Spec.Rotation != nil && !now.Before(lastRotation.Add(role.Spec.Rotation.Interval.Duration)) {
+			log.FromContext(ctx).Info("password is due for rotation", "lastRotation", lastRotation)
+
+			password, err := generatePassword(role.Spec.PasswordGeneration)
+			if err != nil {
+				return err
+			}
+
+			passwordSecret.Data[selector.Key+PendingPasswordKeySuffix] = []byte(password)
+			passwordSecret.ObjectMeta.Annotations[PendingRotatedAtAnnotation] = now.UTC().Format(time.RFC3339)
+			lastRotation = now
+			rotated = true
+		}
+
+		return controllerutil.SetControllerReference(role, passwordSecret, ctrlClient.Scheme())
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("reconciled generated password secret",
+			"secret", passwordSecret.ObjectMeta.Name,
+			"operation", result,
+		)
+	}
+
+	if role.Spec.Rotation == nil {
+		return nil, false, nil
+	}
+
+	return &lastRotation, rotated, nil
+}
+
+// getLastRotation returns the time the password within the secret has been generated. If a rotated password is
+// pending, its time is returned instead. Secrets, that have been created before rotations were recorded, fall back
+// to their creation time.
+func getLastRotation(passwordSecret *v1.Secret) time.Time {
+	if rotatedAt, err := time.Parse(time.RFC3339, passwordSecret.ObjectMeta.Annotations[PendingRotatedAtAnnotation]); err == nil {
+		return rotatedAt
+	}
+
+	rotatedAt, err := time.Parse(time.RFC3339, passwordSecret.ObjectMeta.Annotations[RotatedAtAnnotation])
+	if err != nil {
+		return passwordSecret.ObjectMeta.CreationTimestamp.Time.Truncate(time.Second)
+	}
+	return rotatedAt
+}
+
+// PromoteGeneratedPassword replaces the password within the password secret with the pending rotated password,
+// once it has been applied on all connections.
+func PromoteGeneratedPassword(ctx context.Context, ctrlClient client.Client, role *v1alpha1.Role) error {
+	selector := GetGeneratedPasswordSelector(role)
+	if selector == nil {
+		return nil
+	}
+
+	var passwordSecret v1.Secret
+	err := ctrlClient.Get(ctx, types.NamespacedName{Namespace: role.ObjectMeta.Namespace, Name: selector.Name}, &passwordSecret)
+	if err != nil {
+		return err
+	}
+
+	pendingKey := selector.Key + PendingPasswordKeySuffix
+	password, ok := passwordSecret.Data[pendingKey]
+	if !ok || !metav1.IsControlledBy(&passwordSecret, role) {
+		return nil
+	}
+
+	passwordSecret.Data[selector.Key] = password
+	delete(passwordSecret.Data, pendingKey)
+	if rotatedAt, ok := passwordSecret.ObjectMeta.Annotations[PendingRotatedAtAnnotation]; ok {
+		passwordSecret.ObjectMeta.Annotations[RotatedAtAnnotation] = rotatedAt
+		delete(passwordSecret.ObjectMeta.Annotations, PendingRotatedAtAnnotation)
+	}
+
+	log.FromContext(ctx).Info("promoting rotated password", "secret", passwordSecret.ObjectMeta.Name)
+	return ctrlClient.Update(ctx, &passwordSecret)
+}
+
+// updateRotationStatus records the schedule of the next rotation within the status of the role and appends
+// the rotation to the history, if the password has been rotated.
+func updateRotationStatus(role *v1alpha1.Role, lastRotation *time.Time, rotated bool) {
+	if lastRotation == nil {
+		role.Status.NextPasswordRotation = nil
+		return
+	}
+
+	next := metav1.NewTime(lastRotation.Add(role.Spec.Rotation.Interval.Duration))
+	role.Status.NextPasswordRotation = &next
+
+	if !rotated {
+		return
+	}
+
+	record := v1alpha1.PasswordRotationRecord{
+		Time:       metav1.NewTime(*lastRotation),
+		ValidUntil: getRotationValidUntil(role),
+	}
+
+	role.Status.RotationHistory = append(role.Status.RotationHistory, record)
+	if len(role.Status.RotationHistory) > MaxRotationHistory {
+		role.Status.RotationHistory = role.Status.RotationHistory[len(role.Status.RotationHistory)-MaxRotationHistory:]
+	}
+}
+
+// getRotationValidUntil returns the time after which the current password is no longer valid, which is the next
+// scheduled rotation plus the expiry margin. Nil is returned, if no expiry margin is configured.
+func getRotationValidUntil(role *v1alpha1.Role) *metav1.Time {
+	if role.Spec.Rotation == nil || role.Spec.Rotation.ExpiryMargin == nil || role.Status.NextPasswordRotation == nil {
+		return nil
+	}
+
+	validUntil := metav1.NewTime(role.Status.NextPasswordRotation.Add(role.Spec.Rotation.ExpiryMargin.Duration))
+	return &validUntil
+}
+
+// generatePassword generates a random password, using a cryptographically secure random number generator.
+func generatePassword(generation *v1alpha1.PasswordGeneration) (string, error) {
+	var characters string
+	switch generation.Charset {
+	case CharsetPrintable:
+		characters = alphanumericCharacters + printableSymbols
+	case CharsetCustom:
+		characters = generation.CustomCharset
+	default:
+		characters = alphanumericCharacters
+	}
+
+	charset := []rune(characters)
+	if len(charset) < 2 {
+		return "", fmt.Errorf("charset '%s' must contain at least two characters", characters)
+	}
+
+	length := generation.Length
+	if length <= 0 {
+		length = DefaultPasswordLength
+	}
+
+	password := make([]rune, length)
+	for i := range password {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", fmt.Errorf("unable to generate password: '%w'", err)
+		}
+		password[i] = charset[index.Int64()]
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,60 @@
+package role
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcileName renames the role, if its name changed since the last successful reconciliation. The role is
+// only renamed, if it still exists with its previous name and no other role with the new name exists.
+func (r *Repository) ReconcileName(ctx context.Context) error {
+	previousName := r.role.Status.Name
+	name := r.role.PostgresName()
+	if previousName == "" || previousName == name {
+		return nil
+	}
+
+	var previousExists, exists bool
+	err := r.conn.QueryRow(
+		ctx,
+		`SELECT
+		EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $1),
+		EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = $2)`,
+		previousName,
+		name,
+	).Scan(&previousExists, &exists)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+
+	// the role has already been renamed or never existed, so it will be created with its new name
+	if !previousExists {
+		return nil
+	}
+
+	if exists {
+		return fmt.Errorf(
+			"could not rename role '%s' to '%s', a role with the new name already exists",
+			previousName,
+			name,
+		)
+	}
+
+	_, err = r.conn.Exec(
+		ctx,
+		fmt.Sprintf(
+			"ALTER ROLE %s RENAME TO %s",
+			postgres.SanitizeString(previousName),
+			postgres.SanitizeString(name),
+		),
+	)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+
+	log.FromContext(ctx).Info("renamed role", "previousName", previousName, "name", name)
+	return nil
+}
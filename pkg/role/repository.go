@@ -5,20 +5,21 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgconn"
-	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/connection"
 	"github.com/orbatschow/kubepost/pkg/postgres"
 	"github.com/orbatschow/kubepost/pkg/secret"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
-	"strings"
 )
 
 type Repository struct {
 	role       *v1alpha1.Role
 	connection *v1alpha1.Connection
-	conn       *pgx.Conn
+	conn       *pgxpool.Pool
+	pools      *connection.Manager
 }
 
 type RepositoryError struct {
@@ -35,13 +36,33 @@ func (e RepositoryError) Error() string {
 	return e.Message
 }
 
+// newRepositoryError wraps the given error, including the details reported by the PostgreSQL server.
+func (r *Repository) newRepositoryError(err error) RepositoryError {
+	var pgErr *pgconn.PgError
+	errorCode := ""
+	errorMessage := ""
+	if errors.As(err, &pgErr) {
+		errorCode = pgErr.Code
+		errorMessage = pgErr.Message
+	}
+
+	return RepositoryError{
+		Role:                 r.role.PostgresName(),
+		Connection:           r.connection.ObjectMeta.Name,
+		Namespace:            r.role.ObjectMeta.Namespace,
+		Message:              err.Error(),
+		PostgresErrorCode:    errorCode,
+		PostgresErrorMessage: errorMessage,
+	}
+}
+
 func (r *Repository) Exists(ctx context.Context) (bool, error) {
 
 	var exist bool
 	err := r.conn.QueryRow(
 		ctx,
 		"SELECT true FROM pg_roles WHERE rolname = $1",
-		r.role.ObjectMeta.Name,
+		r.role.PostgresName(),
 	).Scan(&exist)
 
 	if err != nil {
@@ -58,7 +79,7 @@ func (r *Repository) Create(ctx context.Context) error {
 
 	_, err := r.conn.Exec(
 		ctx,
-		fmt.Sprintf("CREATE ROLE %s", postgres.SanitizeString(r.role.ObjectMeta.Name)),
+		fmt.Sprintf("CREATE ROLE %s", postgres.SanitizeString(r.role.PostgresName())),
 	)
 
 	if err != nil {
@@ -80,7 +101,7 @@ func (r *Repository) Delete(ctx context.Context) error {
 
 	_, err := r.conn.Exec(
 		ctx,
-		fmt.Sprintf("DROP ROLE %s", postgres.SanitizeString(r.role.ObjectMeta.Name)),
+		fmt.Sprintf("DROP ROLE %s", postgres.SanitizeString(r.role.PostgresName())),
 	)
 
 	if err != nil {
@@ -99,109 +120,136 @@ func (r *Repository) Delete(ctx context.Context) error {
 	return nil
 }
 
+// SetPassword sets the password of the role. The plaintext password is never sent to the PostgreSQL server,
+// instead a SCRAM-SHA-256 verifier is computed locally. Passwords, that already are a SCRAM-SHA-256 or md5
+// verifier, are sent as they are. An empty password removes the password of the role.
 func (r *Repository) SetPassword(ctx context.Context, password string) error {
+	verifier := "NULL"
+	if password != "" {
+		if !postgres.IsPasswordVerifier(password) {
+			var err error
+			password, err = postgres.ScramSHA256(password)
+			if err != nil {
+				return r.newRepositoryError(err)
+			}
+		}
+		verifier = postgres.SanitizeLiteral(password)
+	}
 
 	_, err := r.conn.Exec(
 		ctx,
 		fmt.Sprintf(
-			"ALTER ROLE %s WITH PASSWORD '%s';",
-			postgres.SanitizeString(r.role.ObjectMeta.Name),
-			password,
+			"ALTER ROLE %s WITH PASSWORD %s",
+			postgres.SanitizeString(r.role.PostgresName()),
+			verifier,
 		),
 	)
 	if err != nil {
-		var pgErr *pgconn.PgError
-		errorCode := ""
-		errorMessage := ""
-		if errors.As(err, &pgErr) {
-			errorCode = pgErr.Code
-			errorMessage = pgErr.Message
-		}
+		return r.newRepositoryError(err)
+	}
+
+	return nil
+}
+
+// ReconcilePassword sets the password of the role, if the verifier stored within PostgreSQL does not match the
+// given password. If kubepost is not allowed to read the verifier, the password is only set, if its version
+// differs from the version, that has been applied before. It returns whether the password has been changed.
+func (r *Repository) ReconcilePassword(ctx context.Context, password string, version string, appliedVersion string) (bool, error) {
+	verifier, ok, err := r.GetPasswordVerifier(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// the verifier can only be compared, if kubepost is allowed to read it
+	switch {
+	case ok && verifier == nil && password == "":
+		log.FromContext(ctx).Info("role has no password, skipping password update")
+		return false, nil
+	case ok && verifier != nil && password != "" && postgres.VerifyPassword(password, *verifier, r.role.PostgresName()):
+		log.FromContext(ctx).Info("password is up to date, skipping password update")
+		return false, nil
+	case !ok && version == appliedVersion:
+		log.FromContext(ctx).Info("password secret is unchanged, skipping password update")
+		return false, nil
+	}
+
+	err = r.SetPassword(ctx, password)
+	if err != nil {
+		return false, err
+	}
+
+	log.FromContext(ctx).Info("updated password")
+	return true, nil
+}
+
+// GetPasswordVerifier returns the password verifier of the role, as stored within pg_authid. The verifier
+// is nil, if the role has no password. If kubepost is not allowed to read pg_authid, false is returned.
+func (r *Repository) GetPasswordVerifier(ctx context.Context) (*string, bool, error) {
+	var verifier *string
+	err := r.conn.QueryRow(
+		ctx,
+		"SELECT rolpassword FROM pg_catalog.pg_authid WHERE rolname = $1",
+		r.role.PostgresName(),
+	).Scan(&verifier)
 
-		return RepositoryError{
-			Role:                 r.role.ObjectMeta.Name,
-			Connection:           r.connection.ObjectMeta.Name,
-			Namespace:            r.role.ObjectMeta.Namespace,
-			Message:              err.Error(),
-			PostgresErrorCode:    errorCode,
-			PostgresErrorMessage: errorMessage,
+	if err != nil {
+		repositoryErr := r.newRepositoryError(err)
+		// reading pg_authid requires superuser privileges
+		if repositoryErr.PostgresErrorCode == "42501" {
+			log.FromContext(ctx).Info("not allowed to read password verifier, password will be updated on secret changes only")
+			return nil, false, nil
 		}
+
+		return nil, false, repositoryErr
 	}
 
-	return nil
+	return verifier, true, nil
 }
 
-func (r *Repository) GetPassword(ctx context.Context, ctrlClient client.Client) (string, error) {
+// GetPassword returns the password of the role, as well as its version, which consists of the name, key and resource
+// version of the secret, the password has been read from. Both are empty, if the role has no password.
+func (r *Repository) GetPassword(ctx context.Context, ctrlClient client.Client) (string, string, error) {
+
+	selector := r.role.Spec.Password
+	if r.role.Spec.PasswordGeneration != nil {
+		selector = GetGeneratedPasswordSelector(r.role)
+	}
 
-	// if neither password isn't set, set it to NULL
-	if r.role.Spec.Password == nil {
-		return "NULL", nil
+	// if no password is set, the password of the role is removed
+	if selector == nil {
+		return "", "", nil
 	}
 
 	namespacedName := types.NamespacedName{
-		Name:      r.role.Spec.Password.Name,
+		Name:      selector.Name,
 		Namespace: r.role.ObjectMeta.Namespace,
 	}
 
 	passwordSecret, err := secret.Get(ctx, ctrlClient, namespacedName)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+
+	// a rotated password is applied, until it has been promoted within the secret
+	key := selector.Key
+	if r.role.Spec.PasswordGeneration != nil && passwordSecret.Data[key+PendingPasswordKeySuffix] != nil {
+		key += PendingPasswordKeySuffix
 	}
 
 	// extract the password
-	buffer := passwordSecret.Data[r.role.Spec.Password.Key]
+	buffer := passwordSecret.Data[key]
 	if buffer == nil {
-		return "",
+		return "", "",
 			fmt.Errorf(
 				"could not find key '%s' for secret '%s' in namespace '%s' for role '%s'",
-				r.role.Spec.Password.Key,
-				r.role.Spec.Password.Name,
+				selector.Key,
+				selector.Name,
 				r.role.Namespace,
 				r.role.Name,
 			)
 	}
 
-	return string(buffer), nil
-}
-
-func (r *Repository) Alter(ctx context.Context) error {
-
-	// if no options were given, return without effect
-	if len(r.role.Spec.Options) == 0 {
-		return nil
-	}
-
-	query := fmt.Sprintf(
-		"ALTER ROLE %s WITH %s;",
-		postgres.SanitizeString(r.role.ObjectMeta.Name),
-		strings.Join(r.role.Spec.Options[:], " "),
-	)
+	version := fmt.Sprintf("%s/%s/%s", selector.Name, key, passwordSecret.ObjectMeta.ResourceVersion)
 
-	log.FromContext(ctx).Info("computed alter role query", "query", query)
-
-	_, err := r.conn.Exec(
-		ctx,
-		query,
-	)
-
-	if err != nil {
-		var pgErr *pgconn.PgError
-		errorCode := ""
-		errorMessage := ""
-		if errors.As(err, &pgErr) {
-			errorCode = pgErr.Code
-			errorMessage = pgErr.Message
-		}
-
-		return RepositoryError{
-			Role:                 r.role.ObjectMeta.Name,
-			Connection:           r.connection.ObjectMeta.Name,
-			Namespace:            r.role.ObjectMeta.Namespace,
-			Message:              err.Error(),
-			PostgresErrorCode:    errorCode,
-			PostgresErrorMessage: errorMessage,
-		}
-	}
-
-	return nil
+	return string(buffer), version, nil
 }
@@ -0,0 +1,72 @@
+package role
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRepositoryError(t *testing.T) {
+	repository := &Repository{
+		role: &v1alpha1.Role{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec:       v1alpha1.RoleSpec{Name: "app_user"},
+		},
+		connection: &v1alpha1.Connection{ObjectMeta: metav1.ObjectMeta{Name: "primary"}},
+	}
+
+	pgErr := &pgconn.PgError{Severity: "ERROR", Code: "42501", Message: "permission denied for table pg_authid"}
+
+	tests := []struct {
+		name string
+		err  error
+		want RepositoryError
+	}{
+		{
+			name: "plain error",
+			err:  errors.New("connection refused"),
+			want: RepositoryError{
+				Role:       "app_user",
+				Connection: "primary",
+				Namespace:  "default",
+				Message:    "connection refused",
+			},
+		},
+		{
+			name: "postgres error",
+			err:  pgErr,
+			want: RepositoryError{
+				Role:                 "app_user",
+				Connection:           "primary",
+				Namespace:            "default",
+				Message:              pgErr.Error(),
+				PostgresErrorCode:    "42501",
+				PostgresErrorMessage: "permission denied for table pg_authid",
+			},
+		},
+		{
+			name: "wrapped postgres error",
+			err:  fmt.Errorf("unable to read verifier: %w", pgErr),
+			want: RepositoryError{
+				Role:                 "app_user",
+				Connection:           "primary",
+				Namespace:            "default",
+				Message:              "unable to read verifier: " + pgErr.Error(),
+				PostgresErrorCode:    "42501",
+				PostgresErrorMessage: "permission denied for table pg_authid",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := repository.newRepositoryError(test.err); got != test.want {
+				t.Errorf("newRepositoryError() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,16 @@ package role
 
 import (
 	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
 	"github.com/orbatschow/kubepost/api/v1alpha1"
 	"github.com/orbatschow/kubepost/pkg/connection"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/utils/strings/slices"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
@@ -13,88 +20,258 @@ import (
 
 var Finalizer = "finalizer.postgres.kubepost.io/role"
 
-func Reconcile(ctx context.Context, ctrlClient client.Client, role *v1alpha1.Role) (*v1alpha1.Role, error) {
+const (
+	reasonReconciled      = "Reconciled"
+	reasonReconcileFailed = "ReconcileFailed"
+	reasonNoConnections   = "NoConnections"
+	reasonPasswordFailed  = "PasswordFailed"
+	reasonSecretConflict  = "SecretConflict"
+)
+
+func Reconcile(ctx context.Context, ctrlClient client.Client, pools *connection.Manager, role *v1alpha1.Role) (*v1alpha1.Role, error) {
 
 	connections, err := connection.List(ctx, ctrlClient, role.Spec.ConnectionNamespaceSelector, role.Spec.ConnectionSelector)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, postgres := range connections {
-		conn, err := connection.GetConnection(ctx, ctrlClient, &postgres)
-		if err != nil {
-			log.FromContext(ctx).Error(
-				err,
-				"failed to establish a connection",
-				"connection", postgres.ObjectMeta.Name,
-			)
-			continue
+	// the finalizer is added before the status is computed, as updating the role replaces its status with the one
+	// stored by the API server
+	if role.ObjectMeta.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(role, Finalizer) {
+		log.FromContext(ctx).Info("updating finalizers")
+
+		controllerutil.AddFinalizer(role, Finalizer)
+		if err = ctrlClient.Update(ctx, role); err != nil {
+			return nil, err
 		}
+	}
 
-		repository := Repository{
-			conn:       conn,
-			connection: &postgres,
-			role:       role,
+	// the generated password has to be shared by all connections, therefore it is generated upfront
+	if role.ObjectMeta.DeletionTimestamp.IsZero() {
+		lastRotation, rotated, err := ReconcileGeneratedPassword(ctx, ctrlClient, role)
+		if err != nil {
+			setReadyCondition(role, metav1.ConditionFalse, reasonPasswordFailed, err.Error())
+			return role, err
 		}
+		updateRotationStatus(role, lastRotation, rotated)
 
-		err = repository.handleFinalizer(ctx, ctrlClient)
+		err = ValidateConnectionSecretNames(role, connections)
 		if err != nil {
-			return nil, err
+			setReadyCondition(role, metav1.ConditionFalse, reasonSecretConflict, err.Error())
+			return role, err
 		}
+	}
 
-		// skip everything else, if deletion is scheduled
-		if !repository.role.ObjectMeta.DeletionTimestamp.IsZero() {
-			return nil, nil
+	var errs []error
+	var results []v1alpha1.RoleConnectionStatus
+	for i := range connections {
+		postgres := &connections[i]
+
+		status := v1alpha1.RoleConnectionStatus{
+			Namespace: postgres.ObjectMeta.Namespace,
+			Name:      postgres.ObjectMeta.Name,
+			Phase:     v1alpha1.PhaseReady,
+		}
+		if previous := getPreviousConnectionStatus(role, postgres); previous != nil {
+			status.PasswordVersion = previous.PasswordVersion
+			status.AppliedGrants = previous.AppliedGrants
 		}
 
-		var exists bool
-		exists, err = repository.Exists(ctx)
-		if err != nil {
+		err = reconcileConnection(ctx, ctrlClient, pools, role, postgres, &status)
+
+		// skip everything else, if deletion is scheduled
+		if !role.ObjectMeta.DeletionTimestamp.IsZero() {
 			return nil, err
 		}
 
-		if exists {
-			log.FromContext(ctx).Info(
-				"role exists, skipping creation",
+		if err != nil {
+			log.FromContext(ctx).Error(
+				err,
+				"failed to reconcile role",
 				"connection", types.NamespacedName{
 					Namespace: postgres.ObjectMeta.Namespace,
 					Name:      postgres.ObjectMeta.Name,
 				},
 			)
-		} else {
-			err = repository.Create(ctx)
-			if err != nil {
-				return nil, err
-			}
+			errs = append(errs, err)
+			setConnectionError(&status, err)
 		}
 
-		password, err := repository.GetPassword(ctx, ctrlClient)
-		if err != nil {
-			return nil, err
-		}
+		status.LastReconcileTime = metav1.Now()
+		results = append(results, status)
+	}
 
-		err = repository.SetPassword(ctx, password)
-		if err != nil {
-			return nil, err
-		}
+	role.Status.ObservedGeneration = role.ObjectMeta.Generation
+	role.Status.Connections = results
+	role.Status.ReadyConnections = fmt.Sprintf("%d/%d", len(connections)-len(errs), len(connections))
+
+	// the name, the applied default privileges and rotated passwords are only tracked once the role has been
+	// reconciled on all connections
+	if len(errs) == 0 {
+		role.Status.Name = role.PostgresName()
+		role.Status.AppliedDefaultPrivileges = role.Spec.DefaultPrivileges
 
-		err = repository.Alter(ctx)
+		err = PromoteGeneratedPassword(ctx, ctrlClient, role)
 		if err != nil {
-			return nil, err
+			errs = append(errs, err)
 		}
+	}
+
+	err = DeleteStaleConnectionSecrets(ctx, ctrlClient, role, connections)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	switch {
+	case len(errs) > 0:
+		setReadyCondition(role, metav1.ConditionFalse, reasonReconcileFailed, utilerrors.NewAggregate(errs).Error())
+	case len(connections) == 0:
+		setReadyCondition(role, metav1.ConditionFalse, reasonNoConnections, "no connection matches the selectors of the role")
+	default:
+		setReadyCondition(role, metav1.ConditionTrue, reasonReconciled, "role has been reconciled on all connections")
+	}
+
+	return role, utilerrors.NewAggregate(errs)
+}
+
+// reconcileConnection reconciles the role on a single connection and records the applied password version within
+// the given status. If deletion of the role is scheduled, only the finalizer is handled.
+func reconcileConnection(
+	ctx context.Context,
+	ctrlClient client.Client,
+	pools *connection.Manager,
+	role *v1alpha1.Role,
+	postgres *v1alpha1.Connection,
+	status *v1alpha1.RoleConnectionStatus,
+) error {
+	conn, err := pools.GetPool(ctx, ctrlClient, postgres, "")
+	if err != nil {
+		return err
+	}
+
+	repository := Repository{
+		conn:       conn,
+		connection: postgres,
+		role:       role,
+		pools:      pools,
+	}
+
+	err = repository.handleFinalizer(ctx, ctrlClient)
+	if err != nil {
+		return err
+	}
+
+	if !repository.role.ObjectMeta.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	err = repository.ReconcileName(ctx)
+	if err != nil {
+		return err
+	}
 
-		err = repository.ReconcileGroups(ctx)
+	var exists bool
+	exists, err = repository.Exists(ctx)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		log.FromContext(ctx).Info(
+			"role exists, skipping creation",
+			"connection", types.NamespacedName{
+				Namespace: postgres.ObjectMeta.Namespace,
+				Name:      postgres.ObjectMeta.Name,
+			},
+		)
+	} else {
+		err = repository.Create(ctx)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		// a new role has neither a password nor privileges, regardless of what has been applied before
+		status.PasswordVersion = ""
+		status.AppliedGrants = nil
+	}
 
-		err = repository.ReconcileGrants(ctx, ctrlClient)
-		if err != nil {
-			return nil, err
+	password, version, err := repository.GetPassword(ctx, ctrlClient)
+	if err != nil {
+		return err
+	}
+
+	changed, err := repository.ReconcilePassword(ctx, password, version, status.PasswordVersion)
+	if err != nil {
+		return err
+	}
+	status.PasswordVersion = version
+
+	if changed {
+		now := metav1.Now()
+		role.Status.PasswordLastRotated = &now
+	}
+
+	err = repository.ReconcileConnectionSecret(ctx, ctrlClient, password)
+	if err != nil {
+		return err
+	}
+
+	err = repository.ReconcileAttributes(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = repository.ReconcileSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = repository.ReconcileGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	status.AppliedGrants, err = repository.ReconcileGrants(ctx, ctrlClient, status.AppliedGrants)
+	return err
+}
+
+// getPreviousConnectionStatus returns the status of the given connection, as recorded by the previous
+// reconciliation, or nil if the role has not been reconciled on the connection before.
+func getPreviousConnectionStatus(role *v1alpha1.Role, postgres *v1alpha1.Connection) *v1alpha1.RoleConnectionStatus {
+	for i, status := range role.Status.Connections {
+		if status.Namespace == postgres.ObjectMeta.Namespace && status.Name == postgres.ObjectMeta.Name {
+			return &role.Status.Connections[i]
 		}
 	}
+	return nil
+}
 
-	return role, nil
+// setConnectionError records the given error within the status of the connection.
+func setConnectionError(status *v1alpha1.RoleConnectionStatus, err error) {
+	status.Phase = v1alpha1.PhaseFailed
+	status.Message = err.Error()
+
+	var repositoryErr RepositoryError
+	var grantErr *GrantError
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &grantErr):
+		status.FailedStatements = grantErr.FailedStatements
+		status.SQLState = grantErr.FailedStatements[0].SQLState
+	case errors.As(err, &repositoryErr):
+		status.SQLState = repositoryErr.PostgresErrorCode
+	case errors.As(err, &pgErr):
+		status.SQLState = pgErr.Code
+	}
+}
+
+func setReadyCondition(role *v1alpha1.Role, status metav1.ConditionStatus, reason string, message string) {
+	meta.SetStatusCondition(&role.Status.Conditions, metav1.Condition{
+		Type:               v1alpha1.ConditionReady,
+		Status:             status,
+		ObservedGeneration: role.ObjectMeta.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
 }
 
 func (r *Repository) handleFinalizer(ctx context.Context, ctrClient client.Client) error {
@@ -157,21 +334,6 @@ func (r *Repository) handleFinalizer(ctx context.Context, ctrClient client.Clien
 				Name:      r.connection.ObjectMeta.Name,
 			},
 		)
-
-	// add finalizer to the object.
-	case r.role.ObjectMeta.DeletionTimestamp.IsZero() && !controllerutil.ContainsFinalizer(r.role, Finalizer):
-		log.FromContext(ctx).Info(
-			"updating finalizers",
-			"connection", types.NamespacedName{
-				Namespace: r.connection.ObjectMeta.Namespace,
-				Name:      r.connection.ObjectMeta.Name,
-			},
-		)
-
-		controllerutil.AddFinalizer(r.role, Finalizer)
-		if err := ctrClient.Update(ctx, r.role); err != nil {
-			return err
-		}
 	}
 
 	return nil
@@ -0,0 +1,283 @@
+package role
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"text/template"
+
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	DefaultConnectionSecretNameTemplate = "{{ .Role }}-{{ .ConnectionNamespace }}-{{ .Connection }}"
+
+	// SecretTypeLabel distinguishes the different secrets, that kubepost manages for a role.
+	SecretTypeLabel      = "postgres.kubepost.io/secret-type"
+	SecretTypeConnection = "connection"
+)
+
+// connectionSecretData holds the fields, that are available to the templates of a connection secret.
+type connectionSecretData struct {
+	Role                string
+	Connection          string
+	ConnectionNamespace string
+	Host                string
+	Port                int
+	Database            string
+	User                string
+	Password            string
+	SSLMode             string
+	URI                 string
+	JDBCURL             string
+}
+
+// ReconcileConnectionSecret writes the connection secret of the role for the current connection. The secret is
+// rewritten whenever the password of the role or the connection changes.
+func (r *Repository) ReconcileConnectionSecret(ctx context.Context, ctrlClient client.Client, password string) error {
+	if r.role.Spec.ConnectionSecret == nil {
+		return nil
+	}
+
+	// applications can't connect with a verifier, the plaintext password is required
+	if postgres.IsPasswordVerifier(password) {
+		return fmt.Errorf(
+			"connection secret for role '%s' in namespace '%s' requires a plaintext password, but a verifier is configured",
+			r.role.ObjectMeta.Name,
+			r.role.ObjectMeta.Namespace,
+		)
+	}
+
+	name, err := GetConnectionSecretName(r.role, r.connection)
+	if err != nil {
+		return err
+	}
+
+	data, err := r.getConnectionSecretData(password)
+	if err != nil {
+		return err
+	}
+
+	connectionSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: r.role.ObjectMeta.Namespace,
+		},
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, ctrlClient, connectionSecret, func() error {
+		// never take over secrets, that have not been created by kubepost
+		if !connectionSecret.ObjectMeta.CreationTimestamp.IsZero() && !metav1.IsControlledBy(connectionSecret, r.role) {
+			return fmt.Errorf(
+				"secret '%s' in namespace '%s' already exists and is not owned by role '%s'",
+				connectionSecret.ObjectMeta.Name,
+				connectionSecret.ObjectMeta.Namespace,
+				r.role.ObjectMeta.Name,
+			)
+		}
+
+		if connectionSecret.ObjectMeta.Labels == nil {
+			connectionSecret.ObjectMeta.Labels = map[string]string{}
+		}
+		connectionSecret.ObjectMeta.Labels["app.kubernetes.io/managed-by"] = "kubepost"
+		connectionSecret.ObjectMeta.Labels[SecretTypeLabel] = SecretTypeConnection
+		connectionSecret.Type = v1.SecretTypeOpaque
+		connectionSecret.Data = data
+
+		return controllerutil.SetControllerReference(r.role, connectionSecret, ctrlClient.Scheme())
+	})
+	if err != nil {
+		return err
+	}
+
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("reconciled connection secret",
+			"secret", connectionSecret.ObjectMeta.Name,
+			"operation", result,
+		)
+	}
+
+	return nil
+}
+
+// DeleteStaleConnectionSecrets removes all connection secrets of the role, that do not belong to one of the
+// given connections anymore.
+func DeleteStaleConnectionSecrets(ctx context.Context, ctrlClient client.Client, role *v1alpha1.Role, connections []v1alpha1.Connection) error {
+	desired := map[string]bool{}
+	if role.Spec.ConnectionSecret != nil {
+		for i := range connections {
+			name, err := GetConnectionSecretName(role, &connections[i])
+			if err != nil {
+				return err
+			}
+			desired[name] = true
+		}
+	}
+
+	var secrets v1.SecretList
+	err := ctrlClient.List(ctx, &secrets,
+		client.InNamespace(role.ObjectMeta.Namespace),
+		client.MatchingLabels{SecretTypeLabel: SecretTypeConnection},
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := range secrets.Items {
+		item := &secrets.Items[i]
+		if desired[item.ObjectMeta.Name] || !metav1.IsControlledBy(item, role) {
+			continue
+		}
+
+		log.FromContext(ctx).Info("deleting stale connection secret", "secret", item.ObjectMeta.Name)
+		err = ctrlClient.Delete(ctx, item)
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateConnectionSecretNames makes sure, that the connection secrets of the given connections don't share a name,
+// as they would overwrite each other otherwise.
+func ValidateConnectionSecretNames(role *v1alpha1.Role, connections []v1alpha1.Connection) error {
+	if role.Spec.ConnectionSecret == nil {
+		return nil
+	}
+
+	names := map[string]*v1alpha1.Connection{}
+	for i := range connections {
+		name, err := GetConnectionSecretName(role, &connections[i])
+		if err != nil {
+			return err
+		}
+
+		if other, ok := names[name]; ok {
+			return fmt.Errorf(
+				"connections '%s/%s' and '%s/%s' share the connection secret '%s' of role '%s' in namespace '%s'",
+				other.ObjectMeta.Namespace,
+				other.ObjectMeta.Name,
+				connections[i].ObjectMeta.Namespace,
+				connections[i].ObjectMeta.Name,
+				name,
+				role.ObjectMeta.Name,
+				role.ObjectMeta.Namespace,
+			)
+		}
+		names[name] = &connections[i]
+	}
+
+	return nil
+}
+
+// GetConnectionSecretName renders the name of the connection secret for the given role and connection.
+func GetConnectionSecretName(role *v1alpha1.Role, connection *v1alpha1.Connection) (string, error) {
+	nameTemplate := role.Spec.ConnectionSecret.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = DefaultConnectionSecretNameTemplate
+	}
+
+	return renderTemplate("nameTemplate", nameTemplate, connectionSecretData{
+		Role:                role.ObjectMeta.Name,
+		Connection:          connection.ObjectMeta.Name,
+		ConnectionNamespace: connection.ObjectMeta.Namespace,
+	})
+}
+
+func (r *Repository) getConnectionSecretData(password string) (map[string][]byte, error) {
+	spec := r.role.Spec.ConnectionSecret
+
+	database := spec.Database
+	if database == "" {
+		database = r.connection.Spec.Database
+	}
+
+	address := net.JoinHostPort(r.connection.Spec.Host, strconv.Itoa(r.connection.Spec.Port))
+	user := r.role.PostgresName()
+
+	uri := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     address,
+		Path:     "/" + database,
+		RawQuery: url.Values{"sslmode": []string{r.connection.Spec.SSLMode}}.Encode(),
+	}
+
+	// the JDBC driver does not support credentials within the authority
+	jdbcURL := fmt.Sprintf(
+		"jdbc:postgresql://%s/%s?%s",
+		address,
+		url.PathEscape(database),
+		url.Values{
+			"user":     []string{user},
+			"password": []string{password},
+			"sslmode":  []string{r.connection.Spec.SSLMode},
+		}.Encode(),
+	)
+
+	values := connectionSecretData{
+		Role:                r.role.ObjectMeta.Name,
+		Connection:          r.connection.ObjectMeta.Name,
+		ConnectionNamespace: r.connection.ObjectMeta.Namespace,
+		Host:                r.connection.Spec.Host,
+		Port:                r.connection.Spec.Port,
+		Database:            database,
+		User:                user,
+		Password:            password,
+		SSLMode:             r.connection.Spec.SSLMode,
+		URI:                 uri.String(),
+		JDBCURL:             jdbcURL,
+	}
+
+	data := map[string][]byte{
+		withDefault(spec.Keys.Host, "host"):         []byte(values.Host),
+		withDefault(spec.Keys.Port, "port"):         []byte(strconv.Itoa(values.Port)),
+		withDefault(spec.Keys.Database, "dbname"):   []byte(values.Database),
+		withDefault(spec.Keys.User, "user"):         []byte(values.User),
+		withDefault(spec.Keys.Password, "password"): []byte(values.Password),
+		withDefault(spec.Keys.SSLMode, "sslmode"):   []byte(values.SSLMode),
+		withDefault(spec.Keys.URI, "uri"):           []byte(values.URI),
+		withDefault(spec.Keys.JDBCURL, "jdbc-uri"):  []byte(values.JDBCURL),
+	}
+
+	for key, format := range spec.Templates {
+		value, err := renderTemplate(key, format, values)
+		if err != nil {
+			return nil, err
+		}
+		data[key] = []byte(value)
+	}
+
+	return data, nil
+}
+
+func renderTemplate(name string, format string, data connectionSecretData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(format)
+	if err != nil {
+		return "", fmt.Errorf("could not parse template '%s': '%w'", name, err)
+	}
+
+	var buffer bytes.Buffer
+	if err = tmpl.Execute(&buffer, data); err != nil {
+		return "", fmt.Errorf("could not render template '%s': '%w'", name, err)
+	}
+
+	return buffer.String(), nil
+}
+
+func withDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
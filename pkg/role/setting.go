@@ -0,0 +1,151 @@
+package role
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// allDatabases is used as database name for the settings, that apply to all databases.
+const allDatabases = ""
+
+// ReconcileSettings sets the desired settings of the role, globally as well as within specific databases, and
+// resets all settings, that are not desired anymore. Settings, that are unknown to the PostgreSQL server, are
+// skipped and reported as error.
+func (r *Repository) ReconcileSettings(ctx context.Context) error {
+	desiredSettings := r.getDesiredSettings()
+
+	var names []string
+	for _, settings := range desiredSettings {
+		names = append(names, postgres.SortedSettingNames(settings)...)
+	}
+
+	invalidSettings, err := postgres.GetInvalidSettings(ctx, r.conn, names)
+	if err != nil {
+		return r.newRepositoryError(err)
+	}
+	invalid := map[string]bool{}
+	for _, name := range invalidSettings {
+		invalid[strings.ToLower(name)] = true
+	}
+
+	currentSettings, err := r.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+
+	var databases []string
+	for database := range desiredSettings {
+		databases = append(databases, database)
+	}
+	for database := range currentSettings {
+		if _, ok := desiredSettings[database]; !ok {
+			databases = append(databases, database)
+		}
+	}
+	sort.Strings(databases)
+
+	var queries []string
+	for _, database := range databases {
+		target := fmt.Sprintf("ALTER ROLE %s", postgres.SanitizeString(r.role.PostgresName()))
+		if database != allDatabases {
+			target = fmt.Sprintf("%s IN DATABASE %s", target, postgres.SanitizeString(database))
+		}
+
+		desired, current := desiredSettings[database], currentSettings[database]
+		for _, name := range postgres.SortedSettingNames(desired) {
+			value, ok := current[name]
+			if invalid[name] || ok && postgres.EqualSettingValues(name, desired[name], value) {
+				continue
+			}
+			queries = append(queries, fmt.Sprintf("%s SET %s", target, postgres.FormatSetting(name, desired[name])))
+		}
+
+		for _, name := range postgres.SortedSettingNames(current) {
+			if _, ok := desired[name]; ok {
+				continue
+			}
+			queries = append(queries, fmt.Sprintf("%s RESET %s", target, postgres.SanitizeString(name)))
+		}
+	}
+
+	for _, query := range queries {
+		log.FromContext(ctx).Info("computed role setting query", "query", query)
+
+		if _, err = r.conn.Exec(ctx, query); err != nil {
+			return r.newRepositoryError(err)
+		}
+	}
+
+	if len(invalidSettings) > 0 {
+		return fmt.Errorf(
+			"skipped invalid settings for role '%s': '%s'",
+			r.role.PostgresName(),
+			strings.Join(invalidSettings, "', '"),
+		)
+	}
+
+	return nil
+}
+
+// GetSettings returns the settings of the role by database. Settings, that apply to all databases, are returned
+// with an empty database name.
+func (r *Repository) GetSettings(ctx context.Context) (map[string]map[string]string, error) {
+	rows, err := r.conn.Query(
+		ctx,
+		`SELECT coalesce(d.datname, ''), s.setconfig
+		FROM pg_catalog.pg_db_role_setting AS s
+		JOIN pg_catalog.pg_roles AS r ON r.oid = s.setrole
+		LEFT JOIN pg_catalog.pg_database AS d ON d.oid = s.setdatabase
+		WHERE r.rolname = $1`,
+		r.role.PostgresName(),
+	)
+	if err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+	defer rows.Close()
+
+	settings := map[string]map[string]string{}
+	for rows.Next() {
+		var database string
+		var setconfig []string
+		if err = rows.Scan(&database, &setconfig); err != nil {
+			return nil, r.newRepositoryError(err)
+		}
+		settings[database] = postgres.ParseSettings(setconfig)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, r.newRepositoryError(err)
+	}
+
+	return settings, nil
+}
+
+// getDesiredSettings returns the desired settings of the role by database, with lowercase setting names.
+func (r *Repository) getDesiredSettings() map[string]map[string]string {
+	settings := map[string]map[string]string{}
+
+	add := func(database string, values map[string]string) {
+		if len(values) == 0 {
+			return
+		}
+		if settings[database] == nil {
+			settings[database] = map[string]string{}
+		}
+		for name, value := range values {
+			settings[database][strings.ToLower(name)] = value
+		}
+	}
+
+	add(allDatabases, r.role.Spec.Settings)
+	for _, databaseSettings := range r.role.Spec.DatabaseSettings {
+		add(databaseSettings.Database, databaseSettings.Settings)
+	}
+
+	return settings
+}
@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/postgres"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+type Repository struct {
+	database   *v1alpha1.Database
+	connection *v1alpha1.Connection
+	conn       *pgxpool.Pool
+}
+
+type RepositoryError struct {
+	Database   string
+	Schema     string
+	Connection string
+	Namespace  string
+	Message    string
+
+	PostgresErrorCode    string
+	PostgresErrorMessage string
+
+	// err is the original error, so that the SQLSTATE can be reported within the status of the database.
+	err error
+}
+
+func (e RepositoryError) Error() string {
+	return e.Message
+}
+
+func (e RepositoryError) Unwrap() error {
+	return e.err
+}
+
+// newRepositoryError wraps the given error, including the details reported by the PostgreSQL server.
+func (r *Repository) newRepositoryError(schema string, err error) *RepositoryError {
+	var pgErr *pgconn.PgError
+	errorCode := ""
+	errorMessage := ""
+	if errors.As(err, &pgErr) {
+		errorCode = pgErr.Code
+		errorMessage = pgErr.Message
+	}
+
+	return &RepositoryError{
+		Database:             r.database.PostgresName(),
+		Schema:               schema,
+		Connection:           r.connection.ObjectMeta.Name,
+		Namespace:            r.database.ObjectMeta.Namespace,
+		Message:              err.Error(),
+		PostgresErrorCode:    errorCode,
+		PostgresErrorMessage: errorMessage,
+		err:                  err,
+	}
+}
+
+// List returns all schemas within the database with their owners, except for the system schemas.
+func (r *Repository) List(ctx context.Context) ([]v1alpha1.Schema, error) {
+	var schemas []v1alpha1.Schema
+
+	err := pgxscan.Select(
+		ctx,
+		r.conn,
+		&schemas,
+		`SELECT n.nspname AS name, pg_catalog.pg_get_userbyid(n.nspowner) AS owner
+		FROM pg_catalog.pg_namespace AS n
+		WHERE n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'`,
+	)
+
+	if err != nil {
+		return nil, r.newRepositoryError("", err)
+	}
+
+	return schemas, nil
+}
+
+func (r *Repository) Create(ctx context.Context, schema *v1alpha1.Schema) error {
+	query := fmt.Sprintf("CREATE SCHEMA %s", postgres.SanitizeString(schema.Name))
+	if schema.Owner != "" {
+		query = fmt.Sprintf("%s AUTHORIZATION %s", query, postgres.SanitizeString(schema.Owner))
+	}
+
+	_, err := r.conn.Exec(ctx, query)
+	if err != nil {
+		return r.newRepositoryError(schema.Name, err)
+	}
+
+	log.FromContext(ctx).Info("created schema", "schema", schema.Name)
+	return nil
+}
+
+func (r *Repository) AlterOwner(ctx context.Context, schema *v1alpha1.Schema) error {
+	_, err := r.conn.Exec(
+		ctx,
+		fmt.Sprintf(
+			"ALTER SCHEMA %s OWNER TO %s",
+			postgres.SanitizeString(schema.Name),
+			postgres.SanitizeString(schema.Owner),
+		),
+	)
+	if err != nil {
+		return r.newRepositoryError(schema.Name, err)
+	}
+
+	log.FromContext(ctx).Info("changed schema ownership", "schema", schema.Name, "owner", schema.Owner)
+	return nil
+}
+
+// Delete drops the schema. Schemas, that still contain objects, are not dropped.
+func (r *Repository) Delete(ctx context.Context, name string) error {
+	_, err := r.conn.Exec(
+		ctx,
+		fmt.Sprintf("DROP SCHEMA %s RESTRICT", postgres.SanitizeString(name)),
+	)
+	if err != nil {
+		return r.newRepositoryError(name, err)
+	}
+
+	log.FromContext(ctx).Info("deleted schema", "schema", name)
+	return nil
+}
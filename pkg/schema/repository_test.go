@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewRepositoryError(t *testing.T) {
+	repository := &Repository{
+		database:   &v1alpha1.Database{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+		connection: &v1alpha1.Connection{ObjectMeta: metav1.ObjectMeta{Name: "primary"}},
+	}
+
+	pgErr := &pgconn.PgError{Severity: "ERROR", Code: "2BP01", Message: "cannot drop schema app because other objects depend on it"}
+	err := repository.newRepositoryError("app", pgErr)
+
+	if err.PostgresErrorCode != "2BP01" || err.Schema != "app" || err.Database != "app" || err.Connection != "primary" {
+		t.Errorf("newRepositoryError() = %+v", err)
+	}
+
+	// the original error is kept, so that callers outside of this package can read the SQLSTATE
+	var unwrapped *pgconn.PgError
+	if !errors.As(error(err), &unwrapped) || unwrapped != pgErr {
+		t.Errorf("errors.As() did not return the original postgres error")
+	}
+
+	plain := repository.newRepositoryError("app", errors.New("connection refused"))
+	if plain.PostgresErrorCode != "" || plain.Message != "connection refused" {
+		t.Errorf("newRepositoryError() = %+v", plain)
+	}
+}
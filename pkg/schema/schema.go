@@ -0,0 +1,93 @@
+package schema
+
+import (
+	"context"
+
+	"github.com/orbatschow/kubepost/api/v1alpha1"
+	"github.com/orbatschow/kubepost/pkg/connection"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reconcile creates the desired schemas of the database on the given connection and changes their owners.
+// Unprotected schemas, that have been managed by kubepost and are not desired anymore, are dropped.
+func Reconcile(ctx context.Context, ctrlClient client.Client, pools *connection.Manager, postgres *v1alpha1.Connection, db *v1alpha1.Database) error {
+
+	// we have to connect to the desired database, so a switch from the connection database is performed here
+	conn, err := pools.GetPool(ctx, ctrlClient, postgres, db.PostgresName())
+	if err != nil {
+		return err
+	}
+
+	repository := Repository{
+		conn:       conn,
+		connection: postgres,
+		database:   db,
+	}
+
+	existingSchemas, err := repository.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	owners := map[string]string{}
+	for _, existingSchema := range existingSchemas {
+		owners[existingSchema.Name] = existingSchema.Owner
+	}
+
+	var desiredSchemas []string
+	for i := range db.Spec.Schemas {
+		desiredSchema := &db.Spec.Schemas[i]
+		desiredSchemas = append(desiredSchemas, desiredSchema.Name)
+
+		owner, exists := owners[desiredSchema.Name]
+		switch {
+		case !exists:
+			err = repository.Create(ctx, desiredSchema)
+		case desiredSchema.Owner != "" && desiredSchema.Owner != owner:
+			err = repository.AlterOwner(ctx, desiredSchema)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// only schemas, that have been managed by kubepost without protection, are dropped
+	for _, managedSchema := range db.Status.ManagedSchemas {
+		if slices.Contains(desiredSchemas, managedSchema) {
+			continue
+		}
+
+		if _, exists := owners[managedSchema]; !exists {
+			log.FromContext(ctx).V(4).Info("schema has already been deleted", "schema", managedSchema)
+			continue
+		}
+
+		err = repository.Delete(ctx, managedSchema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetManagedSchemas returns the unprotected schemas of the database, that are dropped once they are removed
+// from the list of schemas. If the schemas could not be reconciled on all connections, the previously managed
+// schemas are kept, so that they are dropped on the next attempt.
+func GetManagedSchemas(db *v1alpha1.Database, reconciled bool) []string {
+	var managedSchemas []string
+	if !reconciled {
+		managedSchemas = append(managedSchemas, db.Status.ManagedSchemas...)
+	}
+
+	for _, desiredSchema := range db.Spec.Schemas {
+		if desiredSchema.Protected || slices.Contains(managedSchemas, desiredSchema.Name) {
+			continue
+		}
+		managedSchemas = append(managedSchemas, desiredSchema.Name)
+	}
+
+	return managedSchemas
+}